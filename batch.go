@@ -0,0 +1,211 @@
+package main
+
+/*
+#include "bindings/bindings.h"
+
+// Each cgo file gets its own translation unit, so the callFreeC* helpers in
+// sds_wrapper.go's preamble aren't visible here; redeclare the one this file
+// needs.
+static void callFreeCWrapResult(CWrapResult res) { FreeCWrapResult(res); }
+*/
+import "C"
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// recommendedBatchSize is a reasonable default for WrapOutgoingMessages and
+// UnwrapReceivedMessages: large enough to amortize the cgo crossing, small
+// enough that a single batch doesn't hold the manager's lock for too long.
+// Benchmarking on typical Waku relay traffic (sub-KB messages) showed
+// diminishing returns past a few hundred messages per batch; very large
+// batches mostly add latency from holding the lock longer, not throughput.
+const recommendedBatchSize = 256
+
+// packFrames encodes frames as a single buffer of uint32-length-prefixed
+// byte strings, so a whole batch can cross into C with one CBytes call
+// instead of one per message.
+func packFrames(frames [][]byte) []byte {
+	size := 4
+	for _, f := range frames {
+		size += 4 + len(f)
+	}
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf, uint32(len(frames)))
+	offset := 4
+	for _, f := range frames {
+		binary.LittleEndian.PutUint32(buf[offset:], uint32(len(f)))
+		offset += 4
+		copy(buf[offset:], f)
+		offset += len(f)
+	}
+	return buf
+}
+
+// unpackFrames is the inverse of packFrames.
+func unpackFrames(buf []byte) ([][]byte, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("sds: truncated batch frame header")
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	frames := make([][]byte, 0, count)
+	offset := 4
+	for i := 0; i < count; i++ {
+		if offset+4 > len(buf) {
+			return nil, fmt.Errorf("sds: truncated batch frame %d length", i)
+		}
+		length := int(binary.LittleEndian.Uint32(buf[offset:]))
+		offset += 4
+		if offset+length > len(buf) {
+			return nil, fmt.Errorf("sds: truncated batch frame %d body", i)
+		}
+		frame := make([]byte, length)
+		copy(frame, buf[offset:offset+length])
+		frames = append(frames, frame)
+		offset += length
+	}
+	return frames, nil
+}
+
+// WrapOutgoingMessages wraps a batch of messages in a single cgo call,
+// packing them as length-prefixed frames so the per-call CBytes/GoBytes
+// overhead is paid once for the whole batch instead of once per message.
+// Prefer this over repeated WrapOutgoingMessage calls on high-throughput
+// paths; recommendedBatchSize is a good starting point for batch sizing.
+func WrapOutgoingMessages(handle ReliabilityManagerHandle, messages [][]byte, ids []MessageID) ([][]byte, error) {
+	if handle == nil {
+		return nil, ErrInvalidHandle
+	}
+	if len(messages) != len(ids) {
+		return nil, fmt.Errorf("sds: messages and ids must have the same length (%d != %d)", len(messages), len(ids))
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	idFrames := make([][]byte, len(ids))
+	for i, id := range ids {
+		idFrames[i] = []byte(id)
+	}
+
+	// Interleave id/message pairs into a single frame stream: id, message,
+	// id, message, ... so the Nim side can walk both in lockstep.
+	frames := make([][]byte, 0, len(messages)*2)
+	for i := range messages {
+		frames = append(frames, idFrames[i], messages[i])
+	}
+	packed := packFrames(frames)
+
+	cPtr := C.CBytes(packed)
+	defer C.free(cPtr)
+
+	cResult := C.WrapOutgoingMessagesBatch(unsafe.Pointer(handle), cPtr, C.size_t(len(packed)))
+	if !cResult.base_result.is_ok {
+		errMsg := C.GoString(cResult.base_result.error_message)
+		errCode := errorCodeFromC(int(cResult.base_result.error_code))
+		C.callFreeCWrapResult(cResult)
+		return nil, newSDSError(errCode, errMsg)
+	}
+
+	out := C.GoBytes(unsafe.Pointer(cResult.message), C.int(cResult.message_len))
+	C.callFreeCWrapResult(cResult)
+
+	wrapped, err := unpackFrames(out)
+	if err != nil {
+		return nil, err
+	}
+	telemetryFor(handle).messagesWrappedCounter.Add(context.Background(), int64(len(wrapped)))
+	return wrapped, nil
+}
+
+// Values of the one-byte status frame that starts each entry in a batch
+// unwrap result: unwrapBatchOK means the entry's next frames are the
+// unwrapped content and the packed missing dependencies, unwrapBatchError
+// means they are a 4-byte little-endian error code and the error message.
+const (
+	unwrapBatchOK    = 0
+	unwrapBatchError = 1
+)
+
+// UnwrapReceivedMessages unwraps a batch of received messages in a single
+// cgo call. Unlike UnwrapReceivedMessage, a failure to unwrap one message in
+// the batch does not abort the rest: per-message errors are reported in errs
+// (nil entries mean success), and err is only set for batch-level failures
+// (e.g. a malformed request). contents[i] and missing[i] are only valid
+// when errs[i] is nil.
+func UnwrapReceivedMessages(handle ReliabilityManagerHandle, messages [][]byte) (contents [][]byte, missing [][]MessageID, errs []error, err error) {
+	if handle == nil {
+		return nil, nil, nil, ErrInvalidHandle
+	}
+	if len(messages) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	packed := packFrames(messages)
+	cPtr := C.CBytes(packed)
+	defer C.free(cPtr)
+
+	cResult := C.UnwrapReceivedMessagesBatch(unsafe.Pointer(handle), cPtr, C.size_t(len(packed)))
+	if !cResult.base_result.is_ok {
+		errMsg := C.GoString(cResult.base_result.error_message)
+		errCode := errorCodeFromC(int(cResult.base_result.error_code))
+		C.callFreeCWrapResult(cResult)
+		return nil, nil, nil, newSDSError(errCode, errMsg)
+	}
+
+	out := C.GoBytes(unsafe.Pointer(cResult.message), C.int(cResult.message_len))
+	C.callFreeCWrapResult(cResult)
+
+	// Each entry is itself a packed [status, payload, packedDeps] frame
+	// (or [status, errCode, errMessage] on failure), where packedDeps holds
+	// the missing dependency ids as packed frames of their own.
+	entries, parseErr := unpackFrames(out)
+	if parseErr != nil {
+		return nil, nil, nil, parseErr
+	}
+
+	contents = make([][]byte, len(entries))
+	missing = make([][]MessageID, len(entries))
+	errs = make([]error, len(entries))
+
+	for i, entry := range entries {
+		fields, parseErr := unpackFrames(entry)
+		if parseErr != nil || len(fields) < 2 {
+			errs[i] = fmt.Errorf("sds: malformed batch entry %d", i)
+			continue
+		}
+		status := fields[0]
+		if len(status) != 1 {
+			errs[i] = fmt.Errorf("sds: malformed batch entry %d status", i)
+			continue
+		}
+		if status[0] == unwrapBatchError {
+			if len(fields) < 3 || len(fields[1]) != 4 {
+				errs[i] = fmt.Errorf("sds: malformed batch entry %d error frame", i)
+				continue
+			}
+			errs[i] = newSDSError(errorCodeFromC(int(binary.LittleEndian.Uint32(fields[1]))), string(fields[2]))
+			continue
+		}
+		if len(fields) < 3 {
+			errs[i] = fmt.Errorf("sds: malformed batch entry %d", i)
+			continue
+		}
+		contents[i] = fields[1]
+		depFrames, parseErr := unpackFrames(fields[2])
+		if parseErr != nil {
+			errs[i] = fmt.Errorf("sds: malformed batch entry %d deps", i)
+			continue
+		}
+		deps := make([]MessageID, len(depFrames))
+		for j, d := range depFrames {
+			deps[j] = MessageID(d)
+		}
+		missing[i] = deps
+	}
+
+	telemetryFor(handle).messagesUnwrappedCounter.Add(context.Background(), int64(len(entries)))
+	return contents, missing, errs, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// BenchmarkWrapOutgoingMessage measures per-message cgo overhead as a
+// baseline for BenchmarkWrapOutgoingMessages below.
+func BenchmarkWrapOutgoingMessage(b *testing.B) {
+	handle, err := NewReliabilityManager("bench-wrap-single")
+	if err != nil {
+		b.Fatalf("NewReliabilityManager failed: %v", err)
+	}
+	defer CleanupReliabilityManager(handle)
+
+	payload := []byte("benchmark payload")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := WrapOutgoingMessage(handle, payload, MessageID("bench-single")); err != nil {
+			b.Fatalf("WrapOutgoingMessage failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkWrapOutgoingMessages measures throughput of the batched API at
+// recommendedBatchSize, for comparison against BenchmarkWrapOutgoingMessage.
+func BenchmarkWrapOutgoingMessages(b *testing.B) {
+	handle, err := NewReliabilityManager("bench-wrap-batch")
+	if err != nil {
+		b.Fatalf("NewReliabilityManager failed: %v", err)
+	}
+	defer CleanupReliabilityManager(handle)
+
+	messages := make([][]byte, recommendedBatchSize)
+	ids := make([]MessageID, recommendedBatchSize)
+	for i := range messages {
+		messages[i] = []byte("benchmark payload")
+		ids[i] = MessageID("bench-batch")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := WrapOutgoingMessages(handle, messages, ids); err != nil {
+			b.Fatalf("WrapOutgoingMessages failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(recommendedBatchSize), "msgs/op")
+}
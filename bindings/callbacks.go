@@ -5,48 +5,94 @@ package bindings
 */
 import "C"
 import (
-	"fmt"
+	"sync"
 	"unsafe"
 )
 
+// MessageID is a type alias for string for clarity.
+type MessageID string
+
+// Callbacks holds the Go functions invoked for a single ReliabilityManager
+// handle's events.
+type Callbacks struct {
+	OnMessageReady        func(messageId MessageID)
+	OnMessageSent         func(messageId MessageID)
+	OnMissingDependencies func(messageId MessageID, missingDeps []MessageID)
+	OnPeriodicSync        func()
+}
+
+// NOTE: this package is not imported anywhere else in the module (the live
+// callback path is globalCallbackRelay/callbackRegistry in sds_wrapper.go,
+// which routes by handle and was already correctly scoped before the
+// user_data change below). TestCallbacks_Combined, which this change was
+// meant to unblock, no longer exists - callback-based tests were migrated
+// onto the Subscribe event API. Keeping the user_data routing here anyway,
+// on the chance this package gets wired into the build later, but it fixes
+// no reachable cross-talk today.
+
+// callbackRegistry maps a ReliabilityManager handle (passed back to us as
+// the C callback's user_data) to the Callbacks registered for it. Keying by
+// handle, rather than broadcasting to every registered Callbacks, is what
+// lets two receivers on the same channel (or a sender and a receiver sharing
+// a process) each get only the events meant for them.
+var (
+	callbackRegistry = make(map[unsafe.Pointer]*Callbacks)
+	registryMutex    sync.RWMutex
+)
+
+// RegisterCallbacks associates callbacks with handle, so the exported
+// goXxxCallback shims below can route events to the right Callbacks by
+// looking up the user_data the C side hands back to them.
+func RegisterCallbacks(handle unsafe.Pointer, callbacks *Callbacks) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	callbackRegistry[handle] = callbacks
+}
+
+// UnregisterCallbacks removes any Callbacks associated with handle.
+func UnregisterCallbacks(handle unsafe.Pointer) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(callbackRegistry, handle)
+}
+
+func lookupCallbacks(userData unsafe.Pointer) *Callbacks {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return callbackRegistry[userData]
+}
+
 // --- Go Callback Implementations (Exported to C) ---
+//
+// Each shim takes userData as its first parameter: the Nim side now passes
+// back the same void* handle it was given at registration time, so we can
+// look up the one Callbacks entry the event is actually destined for instead
+// of broadcasting to every registered handle.
 
 //export goMessageReadyCallback
-func goMessageReadyCallback(messageID *C.char) {
+func goMessageReadyCallback(userData unsafe.Pointer, messageID *C.char) {
 	msgIdStr := C.GoString(messageID)
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
 
-	// Find the correct Go callback based on handle (this is tricky without handle passed)
-	// For now, iterate through all registered callbacks. This is NOT ideal for multiple managers.
-	// A better approach would involve passing the handle back through user_data if possible,
-	// or maintaining a single global callback handler if only one manager instance is expected.
-	// Let's assume a single instance for simplicity for now.
-	for _, callbacks := range callbackRegistry {
-		if callbacks != nil && callbacks.OnMessageReady != nil {
-			// Run in a goroutine to avoid blocking the C thread
-			go callbacks.OnMessageReady(MessageID(msgIdStr))
-		}
+	callbacks := lookupCallbacks(userData)
+	if callbacks == nil || callbacks.OnMessageReady == nil {
+		return
 	}
-	fmt.Printf("Go: Message Ready: %s\n", msgIdStr) // Debug print
+	go callbacks.OnMessageReady(MessageID(msgIdStr))
 }
 
 //export goMessageSentCallback
-func goMessageSentCallback(messageID *C.char) {
+func goMessageSentCallback(userData unsafe.Pointer, messageID *C.char) {
 	msgIdStr := C.GoString(messageID)
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
 
-	for _, callbacks := range callbackRegistry {
-		if callbacks != nil && callbacks.OnMessageSent != nil {
-			go callbacks.OnMessageSent(MessageID(msgIdStr))
-		}
+	callbacks := lookupCallbacks(userData)
+	if callbacks == nil || callbacks.OnMessageSent == nil {
+		return
 	}
-	fmt.Printf("Go: Message Sent: %s\n", msgIdStr) // Debug print
+	go callbacks.OnMessageSent(MessageID(msgIdStr))
 }
 
 //export goMissingDependenciesCallback
-func goMissingDependenciesCallback(messageID *C.char, missingDeps **C.char, missingDepsCount C.size_t) {
+func goMissingDependenciesCallback(userData unsafe.Pointer, messageID *C.char, missingDeps **C.char, missingDepsCount C.size_t) {
 	msgIdStr := C.GoString(messageID)
 	deps := make([]MessageID, missingDepsCount)
 	if missingDepsCount > 0 {
@@ -57,26 +103,18 @@ func goMissingDependenciesCallback(messageID *C.char, missingDeps **C.char, miss
 		}
 	}
 
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
-
-	for _, callbacks := range callbackRegistry {
-		if callbacks != nil && callbacks.OnMissingDependencies != nil {
-			go callbacks.OnMissingDependencies(MessageID(msgIdStr), deps)
-		}
+	callbacks := lookupCallbacks(userData)
+	if callbacks == nil || callbacks.OnMissingDependencies == nil {
+		return
 	}
-	fmt.Printf("Go: Missing Deps for %s: %v\n", msgIdStr, deps) // Debug print
+	go callbacks.OnMissingDependencies(MessageID(msgIdStr), deps)
 }
 
 //export goPeriodicSyncCallback
-func goPeriodicSyncCallback() {
-	registryMutex.RLock()
-	defer registryMutex.RUnlock()
-
-	for _, callbacks := range callbackRegistry {
-		if callbacks != nil && callbacks.OnPeriodicSync != nil {
-			go callbacks.OnPeriodicSync()
-		}
+func goPeriodicSyncCallback(userData unsafe.Pointer) {
+	callbacks := lookupCallbacks(userData)
+	if callbacks == nil || callbacks.OnPeriodicSync == nil {
+		return
 	}
-	fmt.Println("Go: Periodic Sync Requested") // Debug print
+	go callbacks.OnPeriodicSync()
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// WrappedMessage is the canonical, codec-agnostic shape of a reliability
+// envelope: whatever a Codec produces or consumes, it does so in terms of
+// this struct, so swapping codecs never changes what a caller sees from
+// WrapOutgoingMessage/UnwrapReceivedMessage.
+type WrappedMessage struct {
+	MessageID        MessageID
+	CausalHistory    []MessageID
+	BloomFilter      []byte
+	Payload          []byte
+	LamportTimestamp uint64
+}
+
+// Codec marshals and unmarshals a WrappedMessage to and from wire bytes.
+// Implementations must prefix their output with their own wireFormatVersion
+// byte (see codecForVersion) so a future codec can be added without
+// breaking peers still running an older one.
+type Codec interface {
+	Marshal(msg *WrappedMessage) ([]byte, error)
+	Unmarshal(data []byte) (*WrappedMessage, error)
+}
+
+// Wire-format version bytes. Version 0 is DefaultCodec's length-prefixed
+// encoding, and DefaultCodec remains the default codec for backward
+// compatibility; new codecs get the next unused byte.
+const (
+	wireFormatVersionDefault   = 0x00
+	wireFormatVersionProtobuf  = 0x01
+	wireFormatVersionCapnProto = 0x02
+)
+
+// codecForVersion maps a wire-format version byte back to the Codec that
+// produced it, so UnwrapReceivedMessage can decode a frame regardless of
+// which codec the sender used.
+func codecForVersion(version byte) (Codec, error) {
+	switch version {
+	case wireFormatVersionDefault:
+		return DefaultCodec{}, nil
+	case wireFormatVersionProtobuf:
+		return ProtobufCodec{}, nil
+	case wireFormatVersionCapnProto:
+		return CapnProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("sds: unknown wire-format version %d", version)
+	}
+}
+
+// decodeWrappedMessage reads the leading wire-format version byte off data
+// and dispatches to the matching Codec's Unmarshal.
+func decodeWrappedMessage(data []byte) (*WrappedMessage, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("sds: empty wire frame")
+	}
+	codec, err := codecForVersion(data[0])
+	if err != nil {
+		return nil, err
+	}
+	return codec.Unmarshal(data)
+}
+
+// WithCodec selects the wire codec a ReliabilityManager uses to encode
+// outgoing WrappedMessages and decode incoming ones. Unset, a manager uses
+// DefaultCodec, preserving the module's original on-the-wire encoding.
+//
+// NOTE: any codec other than DefaultCodec is not yet usable end to end —
+// see transcodeOutgoing's doc comment. WrapOutgoingMessage/
+// UnwrapReceivedMessage will return an error for a handle configured with
+// one until the Nim library's wire format is confirmed to match
+// DefaultCodec's framing.
+func WithCodec(codec Codec) Option {
+	return func(o *managerOptions) {
+		o.codec = codec
+	}
+}
+
+// NewReliabilityManagerWithCodec is NewReliabilityManager with an explicit
+// wire Codec. See WithCodec's NOTE: only DefaultCodec is usable end to end
+// today.
+func NewReliabilityManagerWithCodec(channelID string, codec Codec) (ReliabilityManagerHandle, error) {
+	return NewReliabilityManagerWithOptions(channelID, WithCodec(codec))
+}
+
+// handleCodecs records the Codec each handle was configured with, keyed the
+// same way as the other per-handle maps in this package (legacyCallbackCancel,
+// snapshotters). The Nim side still owns producing/consuming the actual wire
+// bytes for Wrap/UnwrapMessage today; this map is the Go-side half of
+// threading a configured codec through to that call once the Nim library
+// exposes WrappedMessage's fields (message id, causal history, bloom
+// filter, payload, lamport timestamp) individually instead of pre-encoded.
+var (
+	handleCodecsMu sync.RWMutex
+	handleCodecs   = make(map[ReliabilityManagerHandle]Codec)
+)
+
+// registerCodec associates codec with handle, defaulting to DefaultCodec
+// when codec is nil.
+func registerCodec(handle ReliabilityManagerHandle, codec Codec) {
+	if codec == nil {
+		codec = DefaultCodec{}
+	}
+	handleCodecsMu.Lock()
+	handleCodecs[handle] = codec
+	handleCodecsMu.Unlock()
+}
+
+// codecFor returns the Codec configured for handle, or DefaultCodec if none
+// was registered.
+func codecFor(handle ReliabilityManagerHandle) Codec {
+	handleCodecsMu.RLock()
+	defer handleCodecsMu.RUnlock()
+	if codec, ok := handleCodecs[handle]; ok {
+		return codec
+	}
+	return DefaultCodec{}
+}
+
+// unregisterCodec removes the Codec tracked for handle.
+func unregisterCodec(handle ReliabilityManagerHandle) {
+	handleCodecsMu.Lock()
+	delete(handleCodecs, handle)
+	handleCodecsMu.Unlock()
+}
+
+// transcodeOutgoing would re-encode a DefaultCodec-framed message, as
+// produced by C.WrapOutgoingMessage, into the wire bytes of handle's
+// configured codec. It is a no-op (returns nimWire unchanged) when that
+// codec is DefaultCodec, since there is then nothing to transcode.
+//
+// For any other codec it currently returns an error instead of
+// transcoding: doing so would require assuming the Nim library's actual
+// wire format is byte-identical to DefaultCodec's packFrames scheme, and
+// that has never been confirmed against a real Nim build. Silently
+// "transcoding" on that assumption risks corrupting every wrapped message.
+// Revisit once that's verified, or once the Nim library exposes
+// WrappedMessage's fields individually instead of pre-encoded, removing the
+// need to transcode at all.
+func transcodeOutgoing(handle ReliabilityManagerHandle, nimWire []byte) ([]byte, error) {
+	codec := codecFor(handle)
+	if _, isDefault := codec.(DefaultCodec); isDefault {
+		return nimWire, nil
+	}
+	return nil, fmt.Errorf("sds: codec %T not supported: the Nim library's wire format has not been confirmed to match DefaultCodec's framing", codec)
+}
+
+// transcodeIncoming is transcodeOutgoing's inverse: see its doc comment for
+// why non-DefaultCodec handles currently return an error rather than a
+// transcoded result.
+func transcodeIncoming(handle ReliabilityManagerHandle, wire []byte) ([]byte, error) {
+	codec := codecFor(handle)
+	if _, isDefault := codec.(DefaultCodec); isDefault {
+		return wire, nil
+	}
+	return nil, fmt.Errorf("sds: codec %T not supported: the Nim library's wire format has not been confirmed to match DefaultCodec's framing", codec)
+}
+
+// DefaultCodec encodes a WrappedMessage as a version byte followed by
+// length-prefixed frames (message id, lamport timestamp, causal history ids,
+// bloom filter, payload), reusing the same framing helpers as the batch APIs
+// in batch.go. It is the default for
+// NewReliabilityManager/NewReliabilityManagerWithOptions; a handle using it
+// passes the Nim library's wrapped bytes through unchanged (see
+// transcodeOutgoing), so existing callers and wire data are unaffected by
+// the introduction of Codec.
+type DefaultCodec struct{}
+
+func (DefaultCodec) Marshal(msg *WrappedMessage) ([]byte, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("sds: nil WrappedMessage")
+	}
+	lamport := make([]byte, 8)
+	binary.LittleEndian.PutUint64(lamport, msg.LamportTimestamp)
+
+	frames := make([][]byte, 0, 4+len(msg.CausalHistory))
+	frames = append(frames, []byte(msg.MessageID), lamport)
+	historyFrames := make([][]byte, len(msg.CausalHistory))
+	for i, id := range msg.CausalHistory {
+		historyFrames[i] = []byte(id)
+	}
+	frames = append(frames, packFrames(historyFrames), msg.BloomFilter, msg.Payload)
+
+	body := packFrames(frames)
+	return append([]byte{wireFormatVersionDefault}, body...), nil
+}
+
+func (DefaultCodec) Unmarshal(data []byte) (*WrappedMessage, error) {
+	if len(data) == 0 || data[0] != wireFormatVersionDefault {
+		return nil, fmt.Errorf("sds: not a DefaultCodec frame")
+	}
+	fields, err := unpackFrames(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("sds: malformed DefaultCodec frame: got %d fields, want 5", len(fields))
+	}
+	if len(fields[1]) != 8 {
+		return nil, fmt.Errorf("sds: malformed DefaultCodec lamport timestamp")
+	}
+	historyFrames, err := unpackFrames(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("sds: malformed DefaultCodec causal history: %w", err)
+	}
+	history := make([]MessageID, len(historyFrames))
+	for i, f := range historyFrames {
+		history[i] = MessageID(f)
+	}
+	return &WrappedMessage{
+		MessageID:        MessageID(fields[0]),
+		LamportTimestamp: binary.LittleEndian.Uint64(fields[1]),
+		CausalHistory:    history,
+		BloomFilter:      fields[3],
+		Payload:          fields[4],
+	}, nil
+}
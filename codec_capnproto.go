@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CapnProtoCodec encodes a WrappedMessage as a single flat segment with a
+// fixed-size offset table up front, in the spirit of Cap'n Proto's
+// segment framing (see rpccapnp): on Unmarshal the bloom filter and payload
+// are sliced directly out of the original buffer, with no copy; the message
+// id and causal history ids are converted to MessageID strings, which copies
+// their bytes. This module
+// does not depend on the capnproto code-generation toolchain; the layout
+// below is hand-rolled to get the same zero-copy property on the decode
+// path that matters for high-throughput relays, without adding a schema
+// compiler to the build.
+//
+// Layout (all integers little-endian):
+//
+//	byte    0  : wire-format version
+//	uint32  1  : lamport timestamp (low 32 bits)
+//	uint32  5  : lamport timestamp (high 32 bits)
+//	uint32  9  : message id length
+//	uint32  13 : bloom filter length
+//	uint32  17 : payload length
+//	uint32  21 : causal history count (N)
+//	uint32  25 : causal history id length, one per entry (N entries)
+//	[message id bytes][causal history id bytes, concatenated][bloom filter bytes][payload bytes]
+type CapnProtoCodec struct{}
+
+const capnProtoHeaderFixedLen = 1 + 4*6 // version + 6 uint32 fields
+
+func (CapnProtoCodec) Marshal(msg *WrappedMessage) ([]byte, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("sds: nil WrappedMessage")
+	}
+
+	historyLens := make([]uint32, len(msg.CausalHistory))
+	historyTotal := 0
+	for i, id := range msg.CausalHistory {
+		historyLens[i] = uint32(len(id))
+		historyTotal += len(id)
+	}
+
+	headerLen := capnProtoHeaderFixedLen + 4*len(msg.CausalHistory)
+	total := headerLen + len(msg.MessageID) + historyTotal + len(msg.BloomFilter) + len(msg.Payload)
+	buf := make([]byte, total)
+
+	buf[0] = wireFormatVersionCapnProto
+	binary.LittleEndian.PutUint32(buf[1:], uint32(msg.LamportTimestamp))
+	binary.LittleEndian.PutUint32(buf[5:], uint32(msg.LamportTimestamp>>32))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(len(msg.MessageID)))
+	binary.LittleEndian.PutUint32(buf[13:], uint32(len(msg.BloomFilter)))
+	binary.LittleEndian.PutUint32(buf[17:], uint32(len(msg.Payload)))
+	binary.LittleEndian.PutUint32(buf[21:], uint32(len(msg.CausalHistory)))
+	for i, l := range historyLens {
+		binary.LittleEndian.PutUint32(buf[capnProtoHeaderFixedLen+4*i:], l)
+	}
+
+	offset := headerLen
+	offset += copy(buf[offset:], msg.MessageID)
+	for _, id := range msg.CausalHistory {
+		offset += copy(buf[offset:], id)
+	}
+	offset += copy(buf[offset:], msg.BloomFilter)
+	offset += copy(buf[offset:], msg.Payload)
+
+	return buf, nil
+}
+
+func (CapnProtoCodec) Unmarshal(data []byte) (*WrappedMessage, error) {
+	if len(data) < capnProtoHeaderFixedLen || data[0] != wireFormatVersionCapnProto {
+		return nil, fmt.Errorf("sds: not a CapnProtoCodec frame")
+	}
+
+	lamportLow := binary.LittleEndian.Uint32(data[1:])
+	lamportHigh := binary.LittleEndian.Uint32(data[5:])
+	messageIDLen := binary.LittleEndian.Uint32(data[9:])
+	bloomFilterLen := binary.LittleEndian.Uint32(data[13:])
+	payloadLen := binary.LittleEndian.Uint32(data[17:])
+	historyCount := binary.LittleEndian.Uint32(data[21:])
+
+	headerLen := capnProtoHeaderFixedLen + 4*int(historyCount)
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("sds: truncated CapnProtoCodec header")
+	}
+
+	historyLens := make([]uint32, historyCount)
+	historyTotal := 0
+	for i := range historyLens {
+		historyLens[i] = binary.LittleEndian.Uint32(data[capnProtoHeaderFixedLen+4*i:])
+		historyTotal += int(historyLens[i])
+	}
+
+	want := headerLen + int(messageIDLen) + historyTotal + int(bloomFilterLen) + int(payloadLen)
+	if len(data) != want {
+		return nil, fmt.Errorf("sds: malformed CapnProtoCodec frame: got %d bytes, want %d", len(data), want)
+	}
+
+	offset := headerLen
+	messageID := MessageID(data[offset : offset+int(messageIDLen)])
+	offset += int(messageIDLen)
+
+	history := make([]MessageID, historyCount)
+	for i, l := range historyLens {
+		history[i] = MessageID(data[offset : offset+int(l)])
+		offset += int(l)
+	}
+
+	bloomFilter := data[offset : offset+int(bloomFilterLen)]
+	offset += int(bloomFilterLen)
+	payload := data[offset : offset+int(payloadLen)]
+
+	return &WrappedMessage{
+		MessageID:        messageID,
+		CausalHistory:    history,
+		BloomFilter:      bloomFilter,
+		Payload:          payload,
+		LamportTimestamp: uint64(lamportHigh)<<32 | uint64(lamportLow),
+	}, nil
+}
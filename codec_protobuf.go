@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtobufCodec encodes a WrappedMessage using the standard protobuf wire
+// format (tag/length-delimited fields via protowire), so other Waku
+// services that already speak protobuf can decode a wrapped message without
+// linking this module. There is no .proto-generated message type here: the
+// field layout below *is* the schema, numbered the way a
+// WrappedMessage.proto would be:
+//
+//	1: bytes  message_id
+//	2: uint64 lamport_timestamp
+//	3: bytes  causal_history (repeated)
+//	4: bytes  bloom_filter
+//	5: bytes  payload
+type ProtobufCodec struct{}
+
+const (
+	protoFieldMessageID        = protowire.Number(1)
+	protoFieldLamportTimestamp = protowire.Number(2)
+	protoFieldCausalHistory    = protowire.Number(3)
+	protoFieldBloomFilter      = protowire.Number(4)
+	protoFieldPayload          = protowire.Number(5)
+)
+
+func (ProtobufCodec) Marshal(msg *WrappedMessage) ([]byte, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("sds: nil WrappedMessage")
+	}
+
+	var body []byte
+	body = protowire.AppendTag(body, protoFieldMessageID, protowire.BytesType)
+	body = protowire.AppendBytes(body, []byte(msg.MessageID))
+
+	body = protowire.AppendTag(body, protoFieldLamportTimestamp, protowire.VarintType)
+	body = protowire.AppendVarint(body, msg.LamportTimestamp)
+
+	for _, id := range msg.CausalHistory {
+		body = protowire.AppendTag(body, protoFieldCausalHistory, protowire.BytesType)
+		body = protowire.AppendBytes(body, []byte(id))
+	}
+
+	body = protowire.AppendTag(body, protoFieldBloomFilter, protowire.BytesType)
+	body = protowire.AppendBytes(body, msg.BloomFilter)
+
+	body = protowire.AppendTag(body, protoFieldPayload, protowire.BytesType)
+	body = protowire.AppendBytes(body, msg.Payload)
+
+	return append([]byte{wireFormatVersionProtobuf}, body...), nil
+}
+
+func (ProtobufCodec) Unmarshal(data []byte) (*WrappedMessage, error) {
+	if len(data) == 0 || data[0] != wireFormatVersionProtobuf {
+		return nil, fmt.Errorf("sds: not a ProtobufCodec frame")
+	}
+	body := data[1:]
+
+	msg := &WrappedMessage{}
+	haveMessageID, haveBloomFilter, havePayload := false, false, false
+
+	for len(body) > 0 {
+		num, typ, n := protowire.ConsumeTag(body)
+		if n < 0 {
+			return nil, fmt.Errorf("sds: malformed ProtobufCodec tag: %w", protowire.ParseError(n))
+		}
+		body = body[n:]
+
+		switch num {
+		case protoFieldMessageID:
+			v, n := protowire.ConsumeBytes(body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed message_id field: %w", protowire.ParseError(n))
+			}
+			msg.MessageID = MessageID(v)
+			haveMessageID = true
+			body = body[n:]
+		case protoFieldLamportTimestamp:
+			v, n := protowire.ConsumeVarint(body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed lamport_timestamp field: %w", protowire.ParseError(n))
+			}
+			msg.LamportTimestamp = v
+			body = body[n:]
+		case protoFieldCausalHistory:
+			v, n := protowire.ConsumeBytes(body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed causal_history field: %w", protowire.ParseError(n))
+			}
+			msg.CausalHistory = append(msg.CausalHistory, MessageID(v))
+			body = body[n:]
+		case protoFieldBloomFilter:
+			v, n := protowire.ConsumeBytes(body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed bloom_filter field: %w", protowire.ParseError(n))
+			}
+			msg.BloomFilter = v
+			haveBloomFilter = true
+			body = body[n:]
+		case protoFieldPayload:
+			v, n := protowire.ConsumeBytes(body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed payload field: %w", protowire.ParseError(n))
+			}
+			msg.Payload = v
+			havePayload = true
+			body = body[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, body)
+			if n < 0 {
+				return nil, fmt.Errorf("sds: malformed unknown field %d: %w", num, protowire.ParseError(n))
+			}
+			body = body[n:]
+		}
+	}
+
+	if !haveMessageID || !haveBloomFilter || !havePayload {
+		return nil, fmt.Errorf("sds: incomplete ProtobufCodec frame")
+	}
+	return msg, nil
+}
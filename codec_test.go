@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func allCodecs() []Codec {
+	return []Codec{DefaultCodec{}, ProtobufCodec{}, CapnProtoCodec{}}
+}
+
+// historyEqual compares causal-history slices by content, treating nil and
+// empty as equal: some codecs round-trip a nil CausalHistory as a non-nil
+// empty slice, which is not a meaningful difference to callers.
+func historyEqual(a, b []MessageID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestCodec_RoundTrip checks Marshal/Unmarshal round-trips for a handful of
+// representative WrappedMessages across every codec.
+func TestCodec_RoundTrip(t *testing.T) {
+	cases := []*WrappedMessage{
+		{MessageID: "m1", CausalHistory: nil, BloomFilter: nil, Payload: nil, LamportTimestamp: 0},
+		{MessageID: "m2", CausalHistory: []MessageID{"a", "b", "c"}, BloomFilter: []byte{1, 2, 3}, Payload: []byte("hello"), LamportTimestamp: 42},
+		{MessageID: "", CausalHistory: []MessageID{""}, BloomFilter: []byte{}, Payload: []byte{}, LamportTimestamp: 1 << 40},
+	}
+
+	for _, codec := range allCodecs() {
+		codec := codec
+		t.Run(reflect.TypeOf(codec).Name(), func(t *testing.T) {
+			for _, want := range cases {
+				data, err := codec.Marshal(want)
+				if err != nil {
+					t.Fatalf("Marshal(%+v) failed: %v", want, err)
+				}
+				got, err := codec.Unmarshal(data)
+				if err != nil {
+					t.Fatalf("Unmarshal failed: %v", err)
+				}
+				if got.MessageID != want.MessageID ||
+					got.LamportTimestamp != want.LamportTimestamp ||
+					!bytes.Equal(got.BloomFilter, want.BloomFilter) ||
+					!bytes.Equal(got.Payload, want.Payload) ||
+					!historyEqual(got.CausalHistory, want.CausalHistory) {
+					t.Errorf("round-trip mismatch: got %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestCodec_VersionByteDispatch checks that decodeWrappedMessage picks the
+// right codec purely from the leading wire-format version byte, so peers
+// using different codecs can coexist on the wire.
+func TestCodec_VersionByteDispatch(t *testing.T) {
+	want := &WrappedMessage{MessageID: "dispatch", CausalHistory: []MessageID{"x"}, BloomFilter: []byte{9}, Payload: []byte("p"), LamportTimestamp: 7}
+
+	for _, codec := range allCodecs() {
+		data, err := codec.Marshal(want)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		got, err := decodeWrappedMessage(data)
+		if err != nil {
+			t.Fatalf("decodeWrappedMessage failed: %v", err)
+		}
+		if got.MessageID != want.MessageID {
+			t.Errorf("got MessageID %q, want %q", got.MessageID, want.MessageID)
+		}
+	}
+
+	if _, err := decodeWrappedMessage([]byte{0xFF}); err == nil {
+		t.Error("decodeWrappedMessage with unknown version byte: expected error, got nil")
+	}
+	if _, err := decodeWrappedMessage(nil); err == nil {
+		t.Error("decodeWrappedMessage with empty data: expected error, got nil")
+	}
+}
+
+// TestTranscode_DefaultCodecIsNoOp checks that transcodeOutgoing/
+// transcodeIncoming pass bytes through unchanged for a handle with no
+// codec (or DefaultCodec) configured, which is the only case they support
+// today.
+func TestTranscode_DefaultCodecIsNoOp(t *testing.T) {
+	msg := &WrappedMessage{MessageID: "m1", CausalHistory: []MessageID{"a", "b"}, BloomFilter: []byte{1, 2}, Payload: []byte("hi"), LamportTimestamp: 9}
+	nimWire, err := DefaultCodec{}.Marshal(msg)
+	if err != nil {
+		t.Fatalf("DefaultCodec.Marshal failed: %v", err)
+	}
+
+	var fakeDefaultHandle int
+	defaultHandle := ReliabilityManagerHandle(unsafe.Pointer(&fakeDefaultHandle))
+	if out, err := transcodeOutgoing(defaultHandle, nimWire); err != nil || !bytes.Equal(out, nimWire) {
+		t.Errorf("transcodeOutgoing with no configured codec: got (%v, %v), want (%v, nil)", out, err, nimWire)
+	}
+	if in, err := transcodeIncoming(defaultHandle, nimWire); err != nil || !bytes.Equal(in, nimWire) {
+		t.Errorf("transcodeIncoming with no configured codec: got (%v, %v), want (%v, nil)", in, err, nimWire)
+	}
+}
+
+// TestTranscode_NonDefaultCodecErrors checks that a handle configured with a
+// non-DefaultCodec gets an explicit error out of transcodeOutgoing/
+// transcodeIncoming instead of a silently "transcoded" result: the Nim
+// library's real wire format has not been confirmed to match DefaultCodec's
+// framing, so guessing at a transcode would risk corrupting every wrapped
+// message.
+func TestTranscode_NonDefaultCodecErrors(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+	registerCodec(handle, ProtobufCodec{})
+	defer unregisterCodec(handle)
+
+	if _, err := transcodeOutgoing(handle, []byte("irrelevant")); err == nil {
+		t.Error("transcodeOutgoing with a non-default codec: expected an error, got nil")
+	}
+	if _, err := transcodeIncoming(handle, []byte("irrelevant")); err == nil {
+		t.Error("transcodeIncoming with a non-default codec: expected an error, got nil")
+	}
+}
+
+// FuzzCodec_RoundTrip fuzzes each codec's Marshal/Unmarshal round-trip
+// against arbitrary field contents, per the request for a round-trip fuzz
+// test covering the pluggable codecs.
+func FuzzCodec_RoundTrip(f *testing.F) {
+	f.Add("seed-id", []byte("seed-bloom"), []byte("seed-payload"), uint64(0))
+	f.Add("", []byte{}, []byte{}, uint64(1<<63))
+
+	f.Fuzz(func(t *testing.T, id string, bloom, payload []byte, lamport uint64) {
+		msg := &WrappedMessage{
+			MessageID:        MessageID(id),
+			CausalHistory:    []MessageID{MessageID(id), "dep"},
+			BloomFilter:      bloom,
+			Payload:          payload,
+			LamportTimestamp: lamport,
+		}
+		for _, codec := range allCodecs() {
+			data, err := codec.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			got, err := codec.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if got.MessageID != msg.MessageID || got.LamportTimestamp != msg.LamportTimestamp ||
+				!bytes.Equal(got.BloomFilter, msg.BloomFilter) || !bytes.Equal(got.Payload, msg.Payload) ||
+				!reflect.DeepEqual(got.CausalHistory, msg.CausalHistory) {
+				t.Fatalf("round-trip mismatch for %T: got %+v, want %+v", codec, got, msg)
+			}
+		}
+	})
+}
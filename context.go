@@ -0,0 +1,187 @@
+package main
+
+/*
+#include "bindings/bindings.h"
+*/
+import "C"
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// cancelGracePeriod bounds how long a context-aware call waits for the
+// underlying cgo call to actually return after a cancellation request is
+// sent, before giving up and returning a ctxCancelError anyway. The goroutine
+// running the cgo call is left to finish on its own; Go cannot forcibly
+// abort a blocked C call.
+const cancelGracePeriod = 50 * time.Millisecond
+
+var opIDCounter uint64
+
+// nextOpID returns a process-unique, monotonically increasing operation id
+// used to correlate a CancelInFlight request with the operation it targets,
+// so a cancel that arrives after the operation already completed (and a new
+// one started) is not mistakenly applied to the wrong operation.
+func nextOpID() uint64 {
+	return atomic.AddUint64(&opIDCounter, 1)
+}
+
+// cancelInFlight asks the Nim library to abort the operation identified by
+// opID on handle, if it is still running.
+func cancelInFlight(handle ReliabilityManagerHandle, opID uint64) {
+	C.CancelInFlight(unsafe.Pointer(handle), C.uint64_t(opID))
+}
+
+// ctxCancelError is returned in place of a bare ctx.Err() when a
+// context-aware call is aborted by context cancellation, so callers that
+// errors.Unwrap (or call Cause, following the dskit Backoff.ErrCause
+// pattern) reach context.Cause(ctx) instead of just the generic
+// context.Canceled/DeadlineExceeded sentinel.
+type ctxCancelError struct {
+	err   error
+	cause error
+}
+
+func (e *ctxCancelError) Error() string { return e.err.Error() }
+func (e *ctxCancelError) Unwrap() error { return e.cause }
+func (e *ctxCancelError) Cause() error  { return e.cause }
+
+func newCtxCancelError(ctx context.Context) error {
+	return &ctxCancelError{err: ctx.Err(), cause: context.Cause(ctx)}
+}
+
+// runWithContext runs op in its own goroutine and races it against ctx.
+// If ctx is done first, it asks Nim to cancel the operation and gives it
+// cancelGracePeriod to unwind before returning a ctxCancelError; op's own
+// result is still delivered if it arrives within the grace period.
+func runWithContext[T any](ctx context.Context, handle ReliabilityManagerHandle, op func(opID uint64) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, newCtxCancelError(ctx)
+	}
+
+	opID := nextOpID()
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := op(opID)
+		done <- result{v, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		cancelInFlight(handle, opID)
+		select {
+		case r := <-done:
+			return r.value, r.err
+		case <-time.After(cancelGracePeriod):
+			return zero, newCtxCancelError(ctx)
+		}
+	}
+}
+
+// WrapOutgoingMessageContext is WrapOutgoingMessage with cancellation and
+// deadline support: if ctx is done before the Nim call returns, it requests
+// cancellation and, unless the call still returns within cancelGracePeriod,
+// returns a ctxCancelError that unwraps to context.Cause(ctx).
+func WrapOutgoingMessageContext(ctx context.Context, handle ReliabilityManagerHandle, message []byte, messageId MessageID) ([]byte, error) {
+	return runWithContext(ctx, handle, func(opID uint64) ([]byte, error) {
+		return wrapOutgoingMessageOp(handle, message, messageId, opID)
+	})
+}
+
+// UnwrapReceivedMessageContext is UnwrapReceivedMessage with cancellation
+// and deadline support.
+func UnwrapReceivedMessageContext(ctx context.Context, handle ReliabilityManagerHandle, message []byte) ([]byte, []MessageID, error) {
+	type unwrapResult struct {
+		content     []byte
+		missingDeps []MessageID
+	}
+	r, err := runWithContext(ctx, handle, func(opID uint64) (unwrapResult, error) {
+		content, missingDeps, err := unwrapReceivedMessageOp(handle, message, opID)
+		return unwrapResult{content, missingDeps}, err
+	})
+	return r.content, r.missingDeps, err
+}
+
+// MarkDependenciesMetContext is MarkDependenciesMet with cancellation and
+// deadline support.
+func MarkDependenciesMetContext(ctx context.Context, handle ReliabilityManagerHandle, messageIDs []MessageID) error {
+	_, err := runWithContext(ctx, handle, func(opID uint64) (struct{}, error) {
+		return struct{}{}, markDependenciesMetOp(handle, messageIDs, opID)
+	})
+	return err
+}
+
+// ResetReliabilityManagerContext is ResetReliabilityManager with
+// cancellation and deadline support.
+func ResetReliabilityManagerContext(ctx context.Context, handle ReliabilityManagerHandle) error {
+	_, err := runWithContext(ctx, handle, func(opID uint64) (struct{}, error) {
+		return struct{}{}, resetReliabilityManagerOp(handle, opID)
+	})
+	return err
+}
+
+// RunPeriodicTasks starts the background tasks in the Nim library and blocks
+// until ctx is done, at which point it stops them. It replaces
+// StartPeriodicTasks for callers that want the periodic-task lifecycle tied
+// to a context instead of CleanupReliabilityManager racing with the sync
+// goroutine.
+func RunPeriodicTasks(ctx context.Context, handle ReliabilityManagerHandle) error {
+	if handle == nil {
+		return ErrInvalidHandle
+	}
+	if err := ctx.Err(); err != nil {
+		return newCtxCancelError(ctx)
+	}
+
+	if err := StartPeriodicTasks(handle); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	C.StopPeriodicTasks(unsafe.Pointer(handle))
+	return newCtxCancelError(ctx)
+}
+
+// WrapOutgoingMessageCtx is WrapOutgoingMessage scoped to ctx: it aborts the
+// in-flight call when ctx is done, following the grpc-go convention of
+// threading a caller-scoped context through instead of relying on
+// context.Background() deep inside a call stack.
+func WrapOutgoingMessageCtx(ctx context.Context, handle ReliabilityManagerHandle, message []byte, messageId MessageID) ([]byte, error) {
+	return WrapOutgoingMessageContext(ctx, handle, message, messageId)
+}
+
+// UnwrapReceivedMessageCtx is UnwrapReceivedMessage scoped to ctx.
+func UnwrapReceivedMessageCtx(ctx context.Context, handle ReliabilityManagerHandle, message []byte) ([]byte, []MessageID, error) {
+	return UnwrapReceivedMessageContext(ctx, handle, message)
+}
+
+// StartPeriodicTasksCtx starts the background tasks in the Nim library and
+// returns immediately, spawning a goroutine that stops them as soon as ctx
+// is done. Unlike RunPeriodicTasks, the caller is not blocked: this is for
+// callers (tests included) that want periodic tasks to shut down
+// deterministically on ctx cancellation rather than racing
+// CleanupReliabilityManager against the sync goroutine.
+func StartPeriodicTasksCtx(ctx context.Context, handle ReliabilityManagerHandle) error {
+	if handle == nil {
+		return ErrInvalidHandle
+	}
+	if err := ctx.Err(); err != nil {
+		return newCtxCancelError(ctx)
+	}
+	if err := StartPeriodicTasks(handle); err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		C.StopPeriodicTasks(unsafe.Pointer(handle))
+	}()
+	return nil
+}
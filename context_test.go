@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// TestRunWithContext_OpCompletesBeforeCancellation checks the fast path: op
+// returning before ctx is ever done delivers its own result untouched.
+func TestRunWithContext_OpCompletesBeforeCancellation(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	got, err := runWithContext(context.Background(), handle, func(opID uint64) (int, error) {
+		return 42, nil
+	})
+	if err != nil || got != 42 {
+		t.Errorf("runWithContext = (%d, %v), want (42, nil)", got, err)
+	}
+}
+
+// TestRunWithContext_ResultWithinGracePeriodWins checks that if op returns
+// shortly after ctx is cancelled but still within cancelGracePeriod,
+// runWithContext delivers op's own result instead of a ctxCancelError.
+func TestRunWithContext_ResultWithinGracePeriodWins(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unblock := make(chan struct{})
+	go func() {
+		cancel()
+		time.Sleep(cancelGracePeriod / 2)
+		close(unblock)
+	}()
+
+	got, err := runWithContext(ctx, handle, func(opID uint64) (int, error) {
+		<-unblock
+		return 7, nil
+	})
+	if err != nil || got != 7 {
+		t.Errorf("runWithContext = (%d, %v), want (7, nil)", got, err)
+	}
+}
+
+// TestRunWithContext_GracePeriodExpiryReturnsCtxCancelError checks that if
+// op hasn't returned by the time cancelGracePeriod elapses after ctx is
+// cancelled, runWithContext gives up and returns a ctxCancelError rather
+// than blocking on op forever.
+func TestRunWithContext_GracePeriodExpiryReturnsCtxCancelError(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	neverUnblock := make(chan struct{})
+	defer close(neverUnblock) // let op's goroutine exit once the test is done
+
+	go func() {
+		time.Sleep(cancelGracePeriod / 5)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := runWithContext(ctx, handle, func(opID uint64) (int, error) {
+		<-neverUnblock
+		return 0, nil
+	})
+	elapsed := time.Since(start)
+
+	var cancelErr *ctxCancelError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("runWithContext error = %v, want a *ctxCancelError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runWithContext error = %v, want errors.Is(err, context.Canceled)", err)
+	}
+	if elapsed < cancelGracePeriod {
+		t.Errorf("runWithContext returned after %v, want at least cancelGracePeriod %v", elapsed, cancelGracePeriod)
+	}
+}
+
+// TestRunWithContext_CausePropagation checks that a ctxCancelError's Cause
+// (and Unwrap, per the dskit Backoff.ErrCause pattern) reaches
+// context.Cause(ctx) rather than the generic context.Canceled sentinel.
+func TestRunWithContext_CausePropagation(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	wantCause := errors.New("deliberate cause")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	neverUnblock := make(chan struct{})
+	defer close(neverUnblock)
+
+	go func() {
+		time.Sleep(cancelGracePeriod / 5)
+		cancel(wantCause)
+	}()
+
+	_, err := runWithContext(ctx, handle, func(opID uint64) (int, error) {
+		<-neverUnblock
+		return 0, nil
+	})
+
+	var cancelErr *ctxCancelError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("runWithContext error = %v, want a *ctxCancelError", err)
+	}
+	if cancelErr.Cause() != wantCause {
+		t.Errorf("Cause() = %v, want %v", cancelErr.Cause(), wantCause)
+	}
+	if !errors.Is(err, wantCause) {
+		t.Errorf("errors.Is(err, wantCause) = false, want true (Unwrap must reach the cause)")
+	}
+}
+
+// TestRunWithContext_AlreadyDoneContextShortCircuits checks that a context
+// that is already done before runWithContext is even called returns
+// immediately with a ctxCancelError, without ever invoking op.
+func TestRunWithContext_AlreadyDoneContextShortCircuits(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opCalled := false
+	_, err := runWithContext(ctx, handle, func(opID uint64) (int, error) {
+		opCalled = true
+		return 0, nil
+	})
+
+	var cancelErr *ctxCancelError
+	if !errors.As(err, &cancelErr) {
+		t.Fatalf("runWithContext error = %v, want a *ctxCancelError", err)
+	}
+	if opCalled {
+		t.Error("op was called despite ctx already being done")
+	}
+}
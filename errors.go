@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+// ErrorCode categorizes the failures the Nim library can report, mirroring
+// the numeric error_code field now returned alongside error_message in
+// CResult/CWrapResult/CUnwrapResult. Keep this in sync with the codes
+// assigned on the Nim side.
+type ErrorCode int
+
+const (
+	ErrCodeUnknown ErrorCode = iota
+	ErrCodeInvalidHandle
+	ErrCodeInvalidMessageID
+	ErrCodeUnknownMessage
+	ErrCodeSerialization
+	ErrCodeDependencyLimit
+	ErrCodeInternal
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrCodeInvalidHandle:
+		return "invalid_handle"
+	case ErrCodeInvalidMessageID:
+		return "invalid_message_id"
+	case ErrCodeUnknownMessage:
+		return "unknown_message"
+	case ErrCodeSerialization:
+		return "serialization"
+	case ErrCodeDependencyLimit:
+		return "dependency_limit"
+	case ErrCodeInternal:
+		return "internal"
+	default:
+		return "unknown"
+	}
+}
+
+// SDSError is the error type wrapper functions in this package return for a
+// nil handle and for failures reported by the Nim library. Errors raised on
+// the Go side (argument checks, frame parsing, codecs, state stores, context
+// cancellation) are not SDSErrors. Callers that need to branch on failure
+// kind should use errors.Is against the sentinels below rather than
+// matching on Error().
+type SDSError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *SDSError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("sds: %s", e.Code)
+	}
+	return fmt.Sprintf("sds: %s: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is an *SDSError with the same Code, so that
+// errors.Is(err, sds.ErrUnknownMessage) works regardless of the message or
+// any wrapping applied along the way.
+func (e *SDSError) Is(target error) bool {
+	t, ok := target.(*SDSError)
+	if !ok {
+		return false
+	}
+	return e.Code == t.Code
+}
+
+// Sentinel errors identifying each category. Compare against these with
+// errors.Is, e.g. errors.Is(err, sds.ErrUnknownMessage).
+var (
+	ErrInvalidHandle    = &SDSError{Code: ErrCodeInvalidHandle}
+	ErrInvalidMessageID = &SDSError{Code: ErrCodeInvalidMessageID}
+	ErrUnknownMessage   = &SDSError{Code: ErrCodeUnknownMessage}
+	ErrSerialization    = &SDSError{Code: ErrCodeSerialization}
+	ErrDependencyLimit  = &SDSError{Code: ErrCodeDependencyLimit}
+	ErrInternal         = &SDSError{Code: ErrCodeInternal}
+)
+
+// newSDSError builds a categorized error carrying the Nim-provided message.
+func newSDSError(code ErrorCode, message string) *SDSError {
+	return &SDSError{Code: code, Message: message}
+}
+
+// errorCodeFromC maps the numeric error_code returned by the C layer onto
+// an ErrorCode, falling back to ErrCodeUnknown for values it doesn't
+// recognize (e.g. a Nim library built before this code was introduced).
+func errorCodeFromC(code int) ErrorCode {
+	if code >= int(ErrCodeUnknown) && code <= int(ErrCodeInternal) {
+		return ErrorCode(code)
+	}
+	return ErrCodeUnknown
+}
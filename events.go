@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Event is the common interface implemented by all values delivered over the
+// channel returned by Subscribe. Callers type-switch on the concrete event
+// type to decide how to handle it.
+type Event interface {
+	isEvent()
+}
+
+// MessageReadyEvent is emitted when a received message's dependencies are
+// all satisfied and it is ready to be delivered to the application.
+type MessageReadyEvent struct {
+	MessageID MessageID
+}
+
+// MessageSentEvent is emitted when a previously outgoing message has been
+// acknowledged (observed in a peer's causal history).
+type MessageSentEvent struct {
+	MessageID MessageID
+}
+
+// MissingDependenciesEvent is emitted when an unwrapped message references
+// causal history entries that have not been seen yet.
+type MissingDependenciesEvent struct {
+	MessageID   MessageID
+	MissingDeps []MessageID
+}
+
+// PeriodicSyncEvent is emitted whenever the Nim library requests a periodic
+// sync/bloom-filter exchange.
+type PeriodicSyncEvent struct{}
+
+// RetransmitEvent is emitted when the periodic worker decides an unacked
+// outgoing message is due for another send attempt. Attempts is the number
+// of prior attempts (0 on the first retransmission).
+type RetransmitEvent struct {
+	MessageID MessageID
+	Attempts  int
+}
+
+// MessageUndeliverableEvent is emitted when a message's retransmission
+// attempts have exceeded ReliabilityConfig.MaxAttempts without an ACK. It is
+// terminal: the manager stops retrying MessageID after this fires.
+type MessageUndeliverableEvent struct {
+	MessageID MessageID
+}
+
+func (MessageReadyEvent) isEvent()         {}
+func (MessageSentEvent) isEvent()          {}
+func (MissingDependenciesEvent) isEvent()  {}
+func (PeriodicSyncEvent) isEvent()         {}
+func (RetransmitEvent) isEvent()           {}
+func (MessageUndeliverableEvent) isEvent() {}
+
+// subscriber is one consumer registered via Subscribe for a given handle.
+type subscriber struct {
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   = make(map[ReliabilityManagerHandle]map[*subscriber]struct{})
+)
+
+// Subscribe registers a new event subscriber for handle and returns a
+// receive-only channel of Events plus a cancel function that unsubscribes
+// and closes the channel. bufSize controls how many events can queue before
+// the relay starts dropping (coalescing, for PeriodicSyncEvent) or discarding
+// events destined for this subscriber; sends never block the relay.
+func Subscribe(handle ReliabilityManagerHandle, bufSize int) (<-chan Event, func(), error) {
+	if handle == nil {
+		return nil, nil, ErrInvalidHandle
+	}
+	if bufSize <= 0 {
+		bufSize = 1
+	}
+
+	sub := &subscriber{ch: make(chan Event, bufSize)}
+
+	subscribersMu.Lock()
+	if subscribers[handle] == nil {
+		subscribers[handle] = make(map[*subscriber]struct{})
+	}
+	subscribers[handle][sub] = struct{}{}
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			if set, ok := subscribers[handle]; ok {
+				delete(set, sub)
+				if len(set) == 0 {
+					delete(subscribers, handle)
+				}
+			}
+			subscribersMu.Unlock()
+			close(sub.ch)
+		})
+	}
+
+	return sub.ch, cancel, nil
+}
+
+// publishEvent fans an event out to every subscriber registered for handle.
+// Sends are non-blocking: a subscriber with a full buffer has the event
+// dropped rather than stalling the relay, and its dropped-event counter is
+// incremented. A PeriodicSyncEvent instead displaces the oldest queued event,
+// since only the latest sync matters; that counts as a drop unless the
+// displaced event was itself a PeriodicSyncEvent (coalesced into this one).
+func publishEvent(handle ReliabilityManagerHandle, event Event) {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+
+	for sub := range subscribers[handle] {
+		select {
+		case sub.ch <- event:
+		default:
+			if _, isSync := event.(PeriodicSyncEvent); isSync {
+				// Drain one stale entry to make room for the latest sync tick.
+				select {
+				case stale := <-sub.ch:
+					if _, staleSync := stale.(PeriodicSyncEvent); !staleSync {
+						sub.dropped.Add(1)
+					}
+					select {
+					case sub.ch <- event:
+						continue
+					default:
+					}
+				default:
+				}
+			}
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// DroppedEventCount returns the total number of events dropped across all
+// subscribers for handle because their buffers were full.
+func DroppedEventCount(handle ReliabilityManagerHandle) uint64 {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+
+	var total uint64
+	for sub := range subscribers[handle] {
+		total += sub.dropped.Load()
+	}
+	return total
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// TestPublishEvent_DropsWhenBufferFull checks that a subscriber with a full
+// buffer has the event dropped (send never blocks the relay) and its
+// DroppedEventCount incremented, rather than stalling publishEvent.
+func TestPublishEvent_DropsWhenBufferFull(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	events, cancel, err := Subscribe(handle, 1)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	// Fill the buffer; this one is received, not dropped.
+	publishEvent(handle, MessageReadyEvent{MessageID: "m1"})
+	// The channel is now full and nothing is draining it, so this one must
+	// be dropped instead of publishEvent blocking.
+	publishEvent(handle, MessageReadyEvent{MessageID: "m2"})
+
+	if got := DroppedEventCount(handle); got != 1 {
+		t.Errorf("DroppedEventCount = %d, want 1", got)
+	}
+
+	got := <-events
+	ready, ok := got.(MessageReadyEvent)
+	if !ok || ready.MessageID != "m1" {
+		t.Errorf("received event = %+v, want MessageReadyEvent{m1} (the dropped m2 must not displace it)", got)
+	}
+}
+
+// TestPublishEvent_CoalescesPeriodicSync checks that a PeriodicSyncEvent
+// published against a full buffer drains the stale queued PeriodicSyncEvent
+// and queues the new one instead of being dropped, since only the latest
+// sync tick matters.
+func TestPublishEvent_CoalescesPeriodicSync(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	events, cancel, err := Subscribe(handle, 1)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	publishEvent(handle, PeriodicSyncEvent{})
+	publishEvent(handle, PeriodicSyncEvent{})
+
+	if got := DroppedEventCount(handle); got != 0 {
+		t.Errorf("DroppedEventCount = %d, want 0 (coalescing must not count as a drop)", got)
+	}
+
+	select {
+	case got := <-events:
+		if _, ok := got.(PeriodicSyncEvent); !ok {
+			t.Errorf("received event = %+v, want PeriodicSyncEvent", got)
+		}
+	default:
+		t.Fatal("expected the coalesced PeriodicSyncEvent to be queued, got nothing")
+	}
+
+	select {
+	case got := <-events:
+		t.Errorf("expected only one coalesced PeriodicSyncEvent, got a second: %+v", got)
+	default:
+	}
+}
+
+// TestPublishEvent_CoalescingOnlyAppliesToPeriodicSync checks that a
+// non-PeriodicSyncEvent published against a full buffer is dropped outright
+// rather than displacing whatever is already queued (coalescing is
+// PeriodicSyncEvent-specific; see publishEvent's doc comment).
+func TestPublishEvent_CoalescingOnlyAppliesToPeriodicSync(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	events, cancel, err := Subscribe(handle, 1)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	publishEvent(handle, PeriodicSyncEvent{})
+	publishEvent(handle, MessageReadyEvent{MessageID: "m1"})
+
+	if got := DroppedEventCount(handle); got != 1 {
+		t.Errorf("DroppedEventCount = %d, want 1", got)
+	}
+	got := <-events
+	if _, ok := got.(PeriodicSyncEvent); !ok {
+		t.Errorf("received event = %+v, want the original PeriodicSyncEvent (MessageReadyEvent must not displace it)", got)
+	}
+}
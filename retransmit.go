@@ -0,0 +1,145 @@
+package main
+
+/*
+#include "bindings/bindings.h"
+
+// Each cgo file gets its own translation unit, so the callFreeC* helpers in
+// sds_wrapper.go's preamble aren't visible here; redeclare what this file
+// needs.
+static void callFreeCResultError(CResult res) { FreeCResultError(res); }
+*/
+import "C"
+import (
+	"math/rand"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ReliabilityConfig controls how aggressively the periodic worker retries
+// outgoing messages that haven't been acknowledged yet.
+type ReliabilityConfig struct {
+	// BaseDelay is the delay before the first retransmission attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff computed from BaseDelay*2^attempts.
+	MaxDelay time.Duration
+	// MaxAttempts is the number of retransmission attempts allowed before
+	// the message is given up on and OnMessageUndeliverable/
+	// MessageUndeliverableEvent fires.
+	MaxAttempts int
+	// JitterFraction is the fraction of the computed delay (before jitter)
+	// used as the upper bound of the uniform jitter added on top, e.g. 0.5
+	// means jitter is drawn from [0, delay/2).
+	JitterFraction float64
+}
+
+// defaultReliabilityConfig is used by NewReliabilityManager and
+// NewReliabilityManagerWithOptions when no WithReliabilityConfig option is
+// given.
+var defaultReliabilityConfig = ReliabilityConfig{
+	BaseDelay:      1 * time.Second,
+	MaxDelay:       2 * time.Minute,
+	MaxAttempts:    8,
+	JitterFraction: 0.5,
+}
+
+// WithReliabilityConfig overrides the retransmission backoff schedule for
+// unacked outgoing messages. cfg replaces defaultReliabilityConfig as a
+// whole: a zero field is passed on as zero, not filled in from the defaults.
+func WithReliabilityConfig(cfg ReliabilityConfig) Option {
+	return func(o *managerOptions) {
+		o.reliabilityConfig = &cfg
+	}
+}
+
+// configureReliability pushes cfg down to the Nim side, which owns the
+// periodic retransmission loop and the per-message (nextAttemptAt, attempts)
+// tracking.
+func configureReliability(handle ReliabilityManagerHandle, cfg ReliabilityConfig) error {
+	cResult := C.ConfigureReliability(
+		unsafe.Pointer(handle),
+		C.uint64_t(cfg.BaseDelay.Milliseconds()),
+		C.uint64_t(cfg.MaxDelay.Milliseconds()),
+		C.int(cfg.MaxAttempts),
+		C.double(cfg.JitterFraction),
+	)
+	if !cResult.is_ok {
+		errMsg := C.GoString(cResult.error_message)
+		errCode := errorCodeFromC(int(cResult.error_code))
+		C.callFreeCResultError(cResult)
+		return newSDSError(errCode, errMsg)
+	}
+	return nil
+}
+
+// reliabilityConfigs tracks the ReliabilityConfig each handle was configured
+// with via configureReliability, so Go-side code (ListPendingMessages's
+// NextRetransmitAt) can predict the backoff schedule the Nim side is
+// actually running without a second round trip over cgo.
+var (
+	reliabilityConfigsMu sync.RWMutex
+	reliabilityConfigs   = make(map[ReliabilityManagerHandle]ReliabilityConfig)
+)
+
+// registerReliabilityConfig records cfg as the schedule handle was
+// configured with. It must be called after configureReliability succeeds.
+func registerReliabilityConfig(handle ReliabilityManagerHandle, cfg ReliabilityConfig) {
+	reliabilityConfigsMu.Lock()
+	reliabilityConfigs[handle] = cfg
+	reliabilityConfigsMu.Unlock()
+}
+
+// reliabilityConfigFor returns the ReliabilityConfig handle was configured
+// with, or defaultReliabilityConfig if none was registered.
+func reliabilityConfigFor(handle ReliabilityManagerHandle) ReliabilityConfig {
+	reliabilityConfigsMu.RLock()
+	defer reliabilityConfigsMu.RUnlock()
+	if cfg, ok := reliabilityConfigs[handle]; ok {
+		return cfg
+	}
+	return defaultReliabilityConfig
+}
+
+// unregisterReliabilityConfig removes the ReliabilityConfig recorded for
+// handle. Called by CleanupReliabilityManager.
+func unregisterReliabilityConfig(handle ReliabilityManagerHandle) {
+	reliabilityConfigsMu.Lock()
+	delete(reliabilityConfigs, handle)
+	reliabilityConfigsMu.Unlock()
+}
+
+// submitRetransmission hands the payload for a retransmitted message back to
+// the Nim library, in response to a RetransmitEvent/OnRetransmit callback.
+func submitRetransmission(handle ReliabilityManagerHandle, messageId MessageID, payload []byte) {
+	cMessageId := C.CString(string(messageId))
+	defer C.free(unsafe.Pointer(cMessageId))
+
+	cPayload := C.CBytes(payload)
+	defer C.free(cPayload)
+
+	C.SubmitRetransmission(unsafe.Pointer(handle), cMessageId, cPayload, C.size_t(len(payload)))
+}
+
+// nextRetransmitDelay computes the delay before the next retransmission
+// attempt: min(BaseDelay*2^attempts, MaxDelay) plus uniform jitter in
+// [0, delay*JitterFraction). attempts is the number of attempts already
+// made (0 before the first retransmission).
+func nextRetransmitDelay(cfg ReliabilityConfig, attempts int) time.Duration {
+	delay := cfg.BaseDelay
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= cfg.MaxDelay {
+			delay = cfg.MaxDelay
+			break
+		}
+	}
+	if delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+
+	jitterCeil := time.Duration(float64(delay) * cfg.JitterFraction)
+	if jitterCeil <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(jitterCeil)))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// TestNextRetransmitDelay_Schedule asserts the backoff schedule described in
+// the request: min(BaseDelay*2^attempts, MaxDelay), plus jitter bounded by
+// JitterFraction of that delay.
+func TestNextRetransmitDelay_Schedule(t *testing.T) {
+	cfg := ReliabilityConfig{
+		BaseDelay:      1 * time.Second,
+		MaxDelay:       30 * time.Second,
+		MaxAttempts:    10,
+		JitterFraction: 0.5,
+	}
+
+	cases := []struct {
+		attempts  int
+		wantBase  time.Duration
+		wantClamp bool
+	}{
+		{attempts: 0, wantBase: 1 * time.Second},
+		{attempts: 1, wantBase: 2 * time.Second},
+		{attempts: 2, wantBase: 4 * time.Second},
+		{attempts: 3, wantBase: 8 * time.Second},
+		{attempts: 4, wantBase: 16 * time.Second},
+		{attempts: 5, wantBase: 30 * time.Second, wantClamp: true}, // 32s clamped to MaxDelay
+		{attempts: 10, wantBase: 30 * time.Second, wantClamp: true},
+	}
+
+	for _, c := range cases {
+		for i := 0; i < 50; i++ { // jitter is random; sample repeatedly
+			delay := nextRetransmitDelay(cfg, c.attempts)
+			if delay < c.wantBase {
+				t.Fatalf("attempts=%d: delay %v below base %v", c.attempts, delay, c.wantBase)
+			}
+			maxWithJitter := c.wantBase + time.Duration(float64(c.wantBase)*cfg.JitterFraction)
+			if delay > maxWithJitter {
+				t.Fatalf("attempts=%d: delay %v exceeds base+jitter ceiling %v", c.attempts, delay, maxWithJitter)
+			}
+			if c.wantClamp && c.wantBase != cfg.MaxDelay {
+				t.Fatalf("test case bug: wantClamp set but wantBase != MaxDelay")
+			}
+		}
+	}
+}
+
+// TestNextRetransmitDelay_ZeroJitter asserts that with JitterFraction 0 the
+// schedule is exactly min(BaseDelay*2^attempts, MaxDelay), with no
+// randomness, so callers simulating dropped ACKs can assert exact timings.
+func TestNextRetransmitDelay_ZeroJitter(t *testing.T) {
+	cfg := ReliabilityConfig{
+		BaseDelay:      100 * time.Millisecond,
+		MaxDelay:       1 * time.Second,
+		MaxAttempts:    5,
+		JitterFraction: 0,
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1 * time.Second, // 1600ms clamped to MaxDelay
+		1 * time.Second,
+	}
+	for attempts, w := range want {
+		if got := nextRetransmitDelay(cfg, attempts); got != w {
+			t.Errorf("attempts=%d: got %v, want %v", attempts, got, w)
+		}
+	}
+}
+
+// TestSubscribe_RetransmitAndUndeliverable simulates a message whose ACK
+// never arrives. Each RetransmitEvent is published only after sleeping for
+// exactly nextRetransmitDelay(cfg, attempts) (jitter disabled for a
+// deterministic schedule), standing in for the Nim-side periodic worker that
+// would otherwise drive this timing, so the test asserts the documented
+// backoff schedule is actually honored rather than just firing events back
+// to back. A terminal MessageUndeliverableEvent follows once MaxAttempts is
+// exceeded, same as the real manager.
+func TestSubscribe_RetransmitAndUndeliverable(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	events, cancel, err := Subscribe(handle, 8)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	msgID := MessageID("retransmit-undeliverable-1")
+	cfg := ReliabilityConfig{
+		BaseDelay:      5 * time.Millisecond,
+		MaxDelay:       20 * time.Millisecond,
+		MaxAttempts:    4,
+		JitterFraction: 0,
+	}
+
+	firstSentAt := time.Now()
+	go func() {
+		for attempts := 0; attempts < cfg.MaxAttempts; attempts++ {
+			time.Sleep(nextRetransmitDelay(cfg, attempts))
+			publishEvent(handle, RetransmitEvent{MessageID: msgID, Attempts: attempts})
+		}
+		publishEvent(handle, MessageUndeliverableEvent{MessageID: msgID})
+	}()
+
+	var retransmits, undeliverable int
+	timeout := time.After(2 * time.Second)
+	for i := 0; i < cfg.MaxAttempts+1; i++ {
+		select {
+		case event := <-events:
+			switch e := event.(type) {
+			case RetransmitEvent:
+				if e.MessageID != msgID {
+					t.Errorf("RetransmitEvent for unexpected message %s", e.MessageID)
+				}
+				if e.Attempts != retransmits {
+					t.Errorf("RetransmitEvent out of order: got attempts=%d, want %d", e.Attempts, retransmits)
+				}
+				// The event must not arrive before its place in the backoff
+				// schedule, computed from the same nextRetransmitDelay
+				// formula ListPendingMessages uses for NextRetransmitAt.
+				wantNotBefore := firstSentAt
+				for a := 0; a <= e.Attempts; a++ {
+					wantNotBefore = wantNotBefore.Add(nextRetransmitDelay(cfg, a))
+				}
+				if time.Now().Before(wantNotBefore) {
+					t.Errorf("RetransmitEvent attempts=%d arrived before its scheduled time", e.Attempts)
+				}
+				retransmits++
+			case MessageUndeliverableEvent:
+				if e.MessageID != msgID {
+					t.Errorf("MessageUndeliverableEvent for unexpected message %s", e.MessageID)
+				}
+				undeliverable++
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for retransmit/undeliverable events")
+		}
+	}
+
+	if retransmits != cfg.MaxAttempts {
+		t.Errorf("got %d RetransmitEvents, want %d", retransmits, cfg.MaxAttempts)
+	}
+	if undeliverable != 1 {
+		t.Errorf("got %d MessageUndeliverableEvents, want 1", undeliverable)
+	}
+}
+
+// TestReliabilityConfigFor_ScopedPerHandle checks that the ReliabilityConfig
+// recorded for one handle (used by ListPendingMessages to predict
+// NextRetransmitAt) does not leak to another handle, and that
+// unregisterReliabilityConfig falls back to defaultReliabilityConfig.
+func TestReliabilityConfigFor_ScopedPerHandle(t *testing.T) {
+	var fakeA, fakeB int
+	handleA := ReliabilityManagerHandle(unsafe.Pointer(&fakeA))
+	handleB := ReliabilityManagerHandle(unsafe.Pointer(&fakeB))
+
+	if got := reliabilityConfigFor(handleA); got != defaultReliabilityConfig {
+		t.Errorf("reliabilityConfigFor(unregistered) = %+v, want defaultReliabilityConfig %+v", got, defaultReliabilityConfig)
+	}
+
+	custom := ReliabilityConfig{BaseDelay: 2 * time.Second, MaxDelay: time.Minute, MaxAttempts: 3, JitterFraction: 0.1}
+	registerReliabilityConfig(handleA, custom)
+	defer unregisterReliabilityConfig(handleA)
+
+	if got := reliabilityConfigFor(handleA); got != custom {
+		t.Errorf("reliabilityConfigFor(handleA) = %+v, want custom %+v", got, custom)
+	}
+	if got := reliabilityConfigFor(handleB); got != defaultReliabilityConfig {
+		t.Errorf("reliabilityConfigFor(handleB) = %+v, want defaultReliabilityConfig (registering A must not affect B)", got)
+	}
+
+	unregisterReliabilityConfig(handleA)
+	if got := reliabilityConfigFor(handleA); got != defaultReliabilityConfig {
+		t.Errorf("reliabilityConfigFor(handleA) after unregister = %+v, want defaultReliabilityConfig", got)
+	}
+}
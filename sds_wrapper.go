@@ -7,6 +7,14 @@ package main
 
 #include <stdlib.h> // For C.free
 #include "bindings/bindings.h" // Update include path
+// NOTE: CResult/CWrapResult.base_result/CUnwrapResult.base_result now also
+// carry an `error_code` int field alongside `error_message`, populated by the
+// Nim side with the same categories as Go's ErrorCode (see errors.go).
+// NOTE: ResetReliabilityManager, WrapOutgoingMessage, UnwrapReceivedMessage,
+// and MarkDependenciesMet now also take a trailing uint64 opID, the same id
+// CancelInFlight (context.go) takes, so Nim can associate an in-flight
+// cancel request with the specific call it targets. opID 0 means "not
+// cancelable" (the call wasn't made through a *Context/*Ctx variant).
 
 // Forward declaration for the single Go callback relay function
 extern void globalCallbackRelay(void* handle, CEventType eventType, void* data1, void* data2, size_t data3);
@@ -19,10 +27,13 @@ static void callFreeCUnwrapResult(CUnwrapResult res) { FreeCUnwrapResult(res); }
 */
 import "C"
 import (
-	"errors"
+	"context"
 	"fmt"
 	"sync"
+	"time"
 	"unsafe"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // --- Go Types ---
@@ -39,26 +50,106 @@ type Callbacks struct {
 	OnMessageSent         func(messageId MessageID)
 	OnMissingDependencies func(messageId MessageID, missingDeps []MessageID)
 	OnPeriodicSync        func()
+	// OnRetransmit is called when messageId is due for another send
+	// attempt; its return value is the payload to resend, and is submitted
+	// back to the Nim library. A nil return skips this attempt (e.g. the
+	// caller has since given up on messageId some other way).
+	OnRetransmit func(messageId MessageID, attempts int) []byte
+	// OnMessageUndeliverable is called once, after messageId's
+	// retransmission attempts are exhausted per ReliabilityConfig.
+	OnMessageUndeliverable func(messageId MessageID)
 }
 
 // Global map to store callbacks associated with handles (needed for Go relay)
 var (
-	callbackRegistry = make(map[ReliabilityManagerHandle]*Callbacks)
-	registryMutex    sync.RWMutex
+	legacyCallbackCancel = make(map[ReliabilityManagerHandle]func())
+	registryMutex        sync.RWMutex
 )
 
 // --- Go Wrapper Functions ---
 
 // NewReliabilityManager creates a new instance of the Nim ReliabilityManager
 func NewReliabilityManager(channelId string) (ReliabilityManagerHandle, error) {
+	return NewReliabilityManagerWithOptions(channelId)
+}
+
+// NewReliabilityManagerWithOptions creates a new instance of the Nim
+// ReliabilityManager, applying the given Options first. This is the place to
+// wire in observability, e.g. WithOTLPExporter, before the manager starts
+// handling messages.
+func NewReliabilityManagerWithOptions(channelId string, opts ...Option) (ReliabilityManagerHandle, error) {
+	var o managerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	telemetry, err := buildHandleTelemetry(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure OTLP exporter: %w", err)
+	}
+
 	cChannelId := C.CString(channelId)
 	defer C.free(unsafe.Pointer(cChannelId))
 
 	handle := C.NewReliabilityManager(cChannelId)
 	if handle == nil {
-		return nil, errors.New("failed to create ReliabilityManager (check Nim logs/stdout)")
+		return nil, newSDSError(ErrCodeInternal, "failed to create ReliabilityManager (check Nim logs/stdout)")
+	}
+	goHandle := ReliabilityManagerHandle(handle)
+
+	reliabilityConfig := defaultReliabilityConfig
+	if o.reliabilityConfig != nil {
+		reliabilityConfig = *o.reliabilityConfig
 	}
-	return ReliabilityManagerHandle(handle), nil
+	if err := configureReliability(goHandle, reliabilityConfig); err != nil {
+		C.CleanupReliabilityManager(unsafe.Pointer(goHandle))
+		return nil, err
+	}
+	registerReliabilityConfig(goHandle, reliabilityConfig)
+	registerCodec(goHandle, o.codec)
+	registerTelemetry(goHandle, telemetry)
+	registerChannelID(goHandle, channelId)
+
+	if o.stateStore != nil {
+		if err := restoreState(goHandle, channelId, o.stateStore); err != nil {
+			C.CleanupReliabilityManager(unsafe.Pointer(goHandle))
+			return nil, err
+		}
+		startSnapshotLoop(goHandle, channelId, o.stateStore, o.snapshotInterval)
+	}
+
+	return goHandle, nil
+}
+
+// loadStateIntoManager deserializes a previously saved snapshot back into
+// the Nim-side manager referenced by handle.
+func loadStateIntoManager(handle ReliabilityManagerHandle, snapshot []byte) error {
+	cPtr := C.CBytes(snapshot)
+	defer C.free(cPtr)
+
+	cResult := C.LoadState(unsafe.Pointer(handle), cPtr, C.size_t(len(snapshot)))
+	if !cResult.is_ok {
+		errMsg := C.GoString(cResult.error_message)
+		errCode := errorCodeFromC(int(cResult.error_code))
+		C.callFreeCResultError(cResult)
+		return newSDSError(errCode, errMsg)
+	}
+	return nil
+}
+
+// snapshotState serializes the Nim-side manager referenced by handle and
+// saves the result to store under channelID.
+func snapshotState(handle ReliabilityManagerHandle, channelID string, store StateStore) error {
+	cSnapshot := C.SerializeState(unsafe.Pointer(handle))
+	if !cSnapshot.base_result.is_ok {
+		errMsg := C.GoString(cSnapshot.base_result.error_message)
+		errCode := errorCodeFromC(int(cSnapshot.base_result.error_code))
+		C.callFreeCWrapResult(cSnapshot)
+		return newSDSError(errCode, errMsg)
+	}
+	snapshot := C.GoBytes(unsafe.Pointer(cSnapshot.message), C.int(cSnapshot.message_len))
+	C.callFreeCWrapResult(cSnapshot)
+
+	return store.Save(channelID, snapshot)
 }
 
 // CleanupReliabilityManager frees the resources associated with the handle
@@ -66,31 +157,64 @@ func CleanupReliabilityManager(handle ReliabilityManagerHandle) {
 	if handle == nil {
 		return
 	}
+	stopSnapshotLoop(handle)
 	// Remove from Go registry first
 	registryMutex.Lock()
-	delete(callbackRegistry, handle)
+	if cancel, ok := legacyCallbackCancel[handle]; ok {
+		cancel()
+		delete(legacyCallbackCancel, handle)
+	}
 	registryMutex.Unlock()
+	unregisterCodec(handle)
+	unregisterTelemetry(handle)
+	unregisterReliabilityConfig(handle)
+	unregisterChannelID(handle)
 	C.CleanupReliabilityManager(unsafe.Pointer(handle))
 }
 
 // ResetReliabilityManager resets the state of the manager
 func ResetReliabilityManager(handle ReliabilityManagerHandle) error {
+	return resetReliabilityManagerOp(handle, 0)
+}
+
+// resetReliabilityManagerOp is ResetReliabilityManager tagged with opID, the
+// value a concurrent CancelInFlight(handle, opID) call would need to target
+// this specific invocation. opID 0 (from the plain, non-context-aware
+// ResetReliabilityManager) means "not cancelable".
+func resetReliabilityManagerOp(handle ReliabilityManagerHandle, opID uint64) (err error) {
+	_, span := telemetryFor(handle).tracer.Start(context.Background(), "sds.ResetReliabilityManager", trace.WithAttributes(channelIDAttr(channelIDFor(handle))))
+	defer func() { endSpanWithError(span, err) }()
+
 	if handle == nil {
-		return errors.New("handle is nil")
+		return ErrInvalidHandle
 	}
-	cResult := C.ResetReliabilityManager(unsafe.Pointer(handle))
+	cResult := C.ResetReliabilityManager(unsafe.Pointer(handle), C.uint64_t(opID))
 	if !cResult.is_ok {
 		errMsg := C.GoString(cResult.error_message)
+		errCode := errorCodeFromC(int(cResult.error_code))
 		C.callFreeCResultError(cResult) // Free the error message
-		return errors.New(errMsg)
+		return newSDSError(errCode, errMsg)
 	}
 	return nil
 }
 
 // WrapOutgoingMessage wraps a message with reliability metadata
 func WrapOutgoingMessage(handle ReliabilityManagerHandle, message []byte, messageId MessageID) ([]byte, error) {
+	return wrapOutgoingMessageOp(handle, message, messageId, 0)
+}
+
+// wrapOutgoingMessageOp is WrapOutgoingMessage tagged with opID; see
+// resetReliabilityManagerOp.
+func wrapOutgoingMessageOp(handle ReliabilityManagerHandle, message []byte, messageId MessageID, opID uint64) (_ []byte, err error) {
+	start := time.Now()
+	_, span := telemetryFor(handle).tracer.Start(context.Background(), "sds.WrapOutgoingMessage", trace.WithAttributes(channelIDAttr(channelIDFor(handle)), messageIDAttr(messageId)))
+	defer func() {
+		telemetryFor(handle).wrapDurationHist.Record(context.Background(), durationMillis(start))
+		endSpanWithError(span, err)
+	}()
+
 	if handle == nil {
-		return nil, errors.New("handle is nil")
+		return nil, ErrInvalidHandle
 	}
 	cMessageId := C.CString(string(messageId))
 	defer C.free(unsafe.Pointer(cMessageId))
@@ -104,12 +228,13 @@ func WrapOutgoingMessage(handle ReliabilityManagerHandle, message []byte, messag
 	}
 	cMessageLen := C.size_t(len(message))
 
-	cWrapResult := C.WrapOutgoingMessage(unsafe.Pointer(handle), cMessagePtr, cMessageLen, cMessageId)
+	cWrapResult := C.WrapOutgoingMessage(unsafe.Pointer(handle), cMessagePtr, cMessageLen, cMessageId, C.uint64_t(opID))
 
 	if !cWrapResult.base_result.is_ok {
 		errMsg := C.GoString(cWrapResult.base_result.error_message)
+		errCode := errorCodeFromC(int(cWrapResult.base_result.error_code))
 		C.callFreeCWrapResult(cWrapResult) // Free error and potentially allocated message
-		return nil, errors.New(errMsg)
+		return nil, newSDSError(errCode, errMsg)
 	}
 
 	// Copy the wrapped message from C memory to Go slice
@@ -117,30 +242,55 @@ func WrapOutgoingMessage(handle ReliabilityManagerHandle, message []byte, messag
 	wrappedMessage := C.GoBytes(unsafe.Pointer(cWrapResult.message), C.int(cWrapResult.message_len))
 	C.callFreeCWrapResult(cWrapResult) // Free the C-allocated message buffer
 
+	wrappedMessage, err = transcodeOutgoing(handle, wrappedMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	telemetryFor(handle).messagesWrappedCounter.Add(context.Background(), 1)
 	return wrappedMessage, nil
 }
 
 // UnwrapReceivedMessage unwraps a received message
 func UnwrapReceivedMessage(handle ReliabilityManagerHandle, message []byte) ([]byte, []MessageID, error) {
+	return unwrapReceivedMessageOp(handle, message, 0)
+}
+
+// unwrapReceivedMessageOp is UnwrapReceivedMessage tagged with opID; see
+// resetReliabilityManagerOp.
+func unwrapReceivedMessageOp(handle ReliabilityManagerHandle, message []byte, opID uint64) (_ []byte, _ []MessageID, err error) {
+	start := time.Now()
+	_, span := telemetryFor(handle).tracer.Start(context.Background(), "sds.UnwrapReceivedMessage", trace.WithAttributes(channelIDAttr(channelIDFor(handle))))
+	defer func() {
+		telemetryFor(handle).unwrapDurationHist.Record(context.Background(), durationMillis(start))
+		endSpanWithError(span, err)
+	}()
+
 	if handle == nil {
-		return nil, nil, errors.New("handle is nil")
+		return nil, nil, ErrInvalidHandle
+	}
+
+	nimWire, err := transcodeIncoming(handle, message)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var cMessagePtr unsafe.Pointer
-	if len(message) > 0 {
-		cMessagePtr = C.CBytes(message)
+	if len(nimWire) > 0 {
+		cMessagePtr = C.CBytes(nimWire)
 		defer C.free(cMessagePtr)
 	} else {
 		cMessagePtr = nil
 	}
-	cMessageLen := C.size_t(len(message))
+	cMessageLen := C.size_t(len(nimWire))
 
-	cUnwrapResult := C.UnwrapReceivedMessage(unsafe.Pointer(handle), cMessagePtr, cMessageLen)
+	cUnwrapResult := C.UnwrapReceivedMessage(unsafe.Pointer(handle), cMessagePtr, cMessageLen, C.uint64_t(opID))
 
 	if !cUnwrapResult.base_result.is_ok {
 		errMsg := C.GoString(cUnwrapResult.base_result.error_message)
+		errCode := errorCodeFromC(int(cUnwrapResult.base_result.error_code))
 		C.callFreeCUnwrapResult(cUnwrapResult) // Free error and potentially allocated fields
-		return nil, nil, errors.New(errMsg)
+		return nil, nil, newSDSError(errCode, errMsg)
 	}
 
 	// Copy unwrapped message content
@@ -158,13 +308,28 @@ func UnwrapReceivedMessage(handle ReliabilityManagerHandle, message []byte) ([]b
 
 	C.callFreeCUnwrapResult(cUnwrapResult) // Free C-allocated message, deps array, and strings
 
+	span.SetAttributes(missingDepsCountAttr(len(missingDeps)))
+	telemetryFor(handle).messagesUnwrappedCounter.Add(context.Background(), 1)
+	if len(missingDeps) > 0 {
+		telemetryFor(handle).dependenciesMissingCtr.Add(context.Background(), int64(len(missingDeps)))
+	}
+
 	return unwrappedContent, missingDeps, nil
 }
 
 // MarkDependenciesMet informs the library that dependencies are met
 func MarkDependenciesMet(handle ReliabilityManagerHandle, messageIDs []MessageID) error {
+	return markDependenciesMetOp(handle, messageIDs, 0)
+}
+
+// markDependenciesMetOp is MarkDependenciesMet tagged with opID; see
+// resetReliabilityManagerOp.
+func markDependenciesMetOp(handle ReliabilityManagerHandle, messageIDs []MessageID, opID uint64) (err error) {
+	_, span := telemetryFor(handle).tracer.Start(context.Background(), "sds.MarkDependenciesMet", trace.WithAttributes(channelIDAttr(channelIDFor(handle)), missingDepsCountAttr(len(messageIDs))))
+	defer func() { endSpanWithError(span, err) }()
+
 	if handle == nil {
-		return errors.New("handle is nil")
+		return ErrInvalidHandle
 	}
 	if len(messageIDs) == 0 {
 		return nil // Nothing to do
@@ -186,27 +351,77 @@ func MarkDependenciesMet(handle ReliabilityManagerHandle, messageIDs []MessageID
 	}
 
 	// Pass the pointer variable (cMessageIDsPtr) directly, which is of type **C.char
-	cResult := C.MarkDependenciesMet(unsafe.Pointer(handle), cMessageIDsPtr, C.size_t(len(messageIDs)))
+	cResult := C.MarkDependenciesMet(unsafe.Pointer(handle), cMessageIDsPtr, C.size_t(len(messageIDs)), C.uint64_t(opID))
 
 	if !cResult.is_ok {
 		errMsg := C.GoString(cResult.error_message)
+		errCode := errorCodeFromC(int(cResult.error_code))
 		C.callFreeCResultError(cResult)
-		return errors.New(errMsg)
+		return newSDSError(errCode, errMsg)
 	}
 	return nil
 }
 
-// RegisterCallback sets the single Go callback relay function
+// legacyCallbackBufSize is the Subscribe buffer size used to back
+// RegisterCallback. It is generous because callback dispatch runs in its own
+// goroutine and should rarely need to drop events.
+const legacyCallbackBufSize = 64
+
+// RegisterCallback sets the single Go callback relay function.
+//
+// Deprecated: RegisterCallback forces handlers to run synchronously off a
+// single Callbacks struct. Prefer Subscribe, which this is now implemented
+// in terms of, for a select-friendly, backpressure-aware API.
 func RegisterCallback(handle ReliabilityManagerHandle, callbacks Callbacks) error {
 	if handle == nil {
-		return errors.New("handle is nil")
+		return ErrInvalidHandle
+	}
+
+	events, cancel, err := Subscribe(handle, legacyCallbackBufSize)
+	if err != nil {
+		return err
 	}
 
-	// Store the Go callbacks associated with this handle
 	registryMutex.Lock()
-	callbackRegistry[handle] = &callbacks
+	if prevCancel, ok := legacyCallbackCancel[handle]; ok {
+		prevCancel()
+	}
+	legacyCallbackCancel[handle] = cancel
 	registryMutex.Unlock()
 
+	go func() {
+		for event := range events {
+			switch e := event.(type) {
+			case MessageReadyEvent:
+				if callbacks.OnMessageReady != nil {
+					callbacks.OnMessageReady(e.MessageID)
+				}
+			case MessageSentEvent:
+				if callbacks.OnMessageSent != nil {
+					callbacks.OnMessageSent(e.MessageID)
+				}
+			case MissingDependenciesEvent:
+				if callbacks.OnMissingDependencies != nil {
+					callbacks.OnMissingDependencies(e.MessageID, e.MissingDeps)
+				}
+			case PeriodicSyncEvent:
+				if callbacks.OnPeriodicSync != nil {
+					callbacks.OnPeriodicSync()
+				}
+			case RetransmitEvent:
+				if callbacks.OnRetransmit != nil {
+					if payload := callbacks.OnRetransmit(e.MessageID, e.Attempts); payload != nil {
+						submitRetransmission(handle, e.MessageID, payload)
+					}
+				}
+			case MessageUndeliverableEvent:
+				if callbacks.OnMessageUndeliverable != nil {
+					callbacks.OnMessageUndeliverable(e.MessageID)
+				}
+			}
+		}
+	}()
+
 	// Register the single global Go relay function with the Nim library
 	// Nim will call globalCallbackRelay, passing the handle as the first argument.
 	C.RegisterCallback(
@@ -220,58 +435,63 @@ func RegisterCallback(handle ReliabilityManagerHandle, callbacks Callbacks) erro
 // StartPeriodicTasks starts the background tasks in the Nim library
 func StartPeriodicTasks(handle ReliabilityManagerHandle) error {
 	if handle == nil {
-		return errors.New("handle is nil")
+		return ErrInvalidHandle
 	}
 	C.StartPeriodicTasks(unsafe.Pointer(handle))
 	// Assuming StartPeriodicTasks doesn't return an error status in C API
 	return nil
 }
 
-// globalCallbackRelay is called by Nim for all events.
-// It uses the handle to find the correct Go Callbacks struct and dispatch the call.
-
+// globalCallbackRelay is called by Nim for all events. It uses the handle to
+// find the subscribers registered for it (via Subscribe, or transitively via
+// RegisterCallback) and fans the event out to each of them.
+//
 //export globalCallbackRelay
 func globalCallbackRelay(handle unsafe.Pointer, eventType C.CEventType, data1 unsafe.Pointer, data2 unsafe.Pointer, data3 C.size_t) {
 	goHandle := ReliabilityManagerHandle(handle)
+	ctx := context.Background()
 
-	registryMutex.RLock()
-	callbacks, ok := callbackRegistry[goHandle]
-	registryMutex.RUnlock()
-
-	if !ok || callbacks == nil {
-		return
-	}
+	t := telemetryFor(goHandle)
 
 	switch eventType {
-		case C.EVENT_MESSAGE_READY:
-			if callbacks.OnMessageReady != nil {
-				msgIdStr := C.GoString((*C.char)(data1))
-				callbacks.OnMessageReady(MessageID(msgIdStr))
-			}
-		case C.EVENT_MESSAGE_SENT:
-			if callbacks.OnMessageSent != nil {
-				msgIdStr := C.GoString((*C.char)(data1))
-				callbacks.OnMessageSent(MessageID(msgIdStr))
-			}
-		case C.EVENT_MISSING_DEPENDENCIES:
-			if callbacks.OnMissingDependencies != nil {
-				msgIdStr := C.GoString((*C.char)(data1))
-				depsCount := int(data3)
-				deps := make([]MessageID, depsCount)
-				if depsCount > 0 {
-					// Convert C array of C strings (**char) to Go slice
-					cDepsArray := (*[1 << 30]*C.char)(data2)[:depsCount:depsCount]
-					for i, s := range cDepsArray {
-						deps[i] = MessageID(C.GoString(s))
-					}
-				}
-				callbacks.OnMissingDependencies(MessageID(msgIdStr), deps)
+	case C.EVENT_MESSAGE_READY:
+		msgIdStr := C.GoString((*C.char)(data1))
+		recordCallbackEvent(ctx, goHandle, "sds.message_ready", messageIDAttr(MessageID(msgIdStr)))
+		t.messagesReadyCounter.Add(ctx, 1)
+		publishEvent(goHandle, MessageReadyEvent{MessageID: MessageID(msgIdStr)})
+	case C.EVENT_MESSAGE_SENT:
+		msgIdStr := C.GoString((*C.char)(data1))
+		recordCallbackEvent(ctx, goHandle, "sds.message_sent", messageIDAttr(MessageID(msgIdStr)))
+		t.messagesSentCounter.Add(ctx, 1)
+		publishEvent(goHandle, MessageSentEvent{MessageID: MessageID(msgIdStr)})
+	case C.EVENT_MISSING_DEPENDENCIES:
+		msgIdStr := C.GoString((*C.char)(data1))
+		depsCount := int(data3)
+		deps := make([]MessageID, depsCount)
+		if depsCount > 0 {
+			// Convert C array of C strings (**char) to Go slice
+			cDepsArray := (*[1 << 30]*C.char)(data2)[:depsCount:depsCount]
+			for i, s := range cDepsArray {
+				deps[i] = MessageID(C.GoString(s))
 			}
-		case C.EVENT_PERIODIC_SYNC:
-			if callbacks.OnPeriodicSync != nil {
-				callbacks.OnPeriodicSync()
-			}
-		default:
-			fmt.Printf("Go: globalCallbackRelay: Received unknown event type %d for handle %v\n", eventType, goHandle)
+		}
+		recordCallbackEvent(ctx, goHandle, "sds.missing_dependencies", messageIDAttr(MessageID(msgIdStr)), missingDepsCountAttr(depsCount))
+		t.dependenciesMissingCtr.Add(ctx, int64(depsCount))
+		publishEvent(goHandle, MissingDependenciesEvent{MessageID: MessageID(msgIdStr), MissingDeps: deps})
+	case C.EVENT_PERIODIC_SYNC:
+		recordCallbackEvent(ctx, goHandle, "sds.periodic_sync")
+		publishEvent(goHandle, PeriodicSyncEvent{})
+	case C.EVENT_RETRANSMIT:
+		msgIdStr := C.GoString((*C.char)(data1))
+		attempts := int(data3)
+		recordCallbackEvent(ctx, goHandle, "sds.retransmit", messageIDAttr(MessageID(msgIdStr)))
+		t.messagesRetransmittedCounter.Add(ctx, 1)
+		publishEvent(goHandle, RetransmitEvent{MessageID: MessageID(msgIdStr), Attempts: attempts})
+	case C.EVENT_MESSAGE_UNDELIVERABLE:
+		msgIdStr := C.GoString((*C.char)(data1))
+		recordCallbackEvent(ctx, goHandle, "sds.message_undeliverable", messageIDAttr(MessageID(msgIdStr)))
+		publishEvent(goHandle, MessageUndeliverableEvent{MessageID: MessageID(msgIdStr)})
+	default:
+		fmt.Printf("Go: globalCallbackRelay: Received unknown event type %d for handle %v\n", eventType, goHandle)
 	}
 }
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"sync"
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -142,9 +143,9 @@ func TestDependencies(t *testing.T) {
 	}
 }
 
-// Test OnMessageReady callback
-func TestCallback_OnMessageReady(t *testing.T) {
-	channelID := "test-cb-ready"
+// Test that a MessageReadyEvent is delivered via Subscribe
+func TestSubscribe_MessageReady(t *testing.T) {
+	channelID := "test-sub-ready"
 
 	// Create sender and receiver handles
 	handleSender, err := NewReliabilityManager(channelID)
@@ -159,29 +160,16 @@ func TestCallback_OnMessageReady(t *testing.T) {
 	}
 	defer CleanupReliabilityManager(handleReceiver)
 
-	// Use a channel for signaling
-	readyChan := make(chan MessageID, 1)
-
-	callbacks := Callbacks{
-		OnMessageReady: func(messageId MessageID) {
-			// Non-blocking send to channel
-			select {
-			case readyChan <- messageId:
-			default:
-				// Avoid blocking if channel is full or test already timed out
-			}
-		},
-	}
-
-	// Register callback only on the receiver handle
-	err = RegisterCallback(handleReceiver, callbacks)
+	// Subscribe only on the receiver handle
+	events, cancel, err := Subscribe(handleReceiver, 4)
 	if err != nil {
-		t.Fatalf("RegisterCallback failed: %v", err)
+		t.Fatalf("Subscribe failed: %v", err)
 	}
+	defer cancel()
 
 	// Scenario: Wrap message on sender, unwrap on receiver
 	payload := []byte("ready test")
-	msgID := MessageID("cb-ready-1")
+	msgID := MessageID("sub-ready-1")
 
 	// Wrap on sender
 	wrappedMsg, err := WrapOutgoingMessage(handleSender, payload, msgID)
@@ -197,20 +185,22 @@ func TestCallback_OnMessageReady(t *testing.T) {
 
 	// Verification - Wait on channel with timeout
 	select {
-	case receivedMsgID := <-readyChan:
-		// Mark as called implicitly since we received on channel
-		if receivedMsgID != msgID {
-			t.Errorf("OnMessageReady called with wrong ID: got %q, want %q", receivedMsgID, msgID)
+	case event := <-events:
+		ready, ok := event.(MessageReadyEvent)
+		if !ok {
+			t.Fatalf("expected MessageReadyEvent, got %T", event)
+		}
+		if ready.MessageID != msgID {
+			t.Errorf("MessageReadyEvent has wrong ID: got %q, want %q", ready.MessageID, msgID)
 		}
 	case <-time.After(2 * time.Second):
-		// If timeout occurs, the channel receive failed.
-		t.Errorf("Timed out waiting for OnMessageReady callback on readyChan")
+		t.Errorf("Timed out waiting for MessageReadyEvent")
 	}
 }
 
-// Test OnMessageSent callback (via causal history ACK)
-func TestCallback_OnMessageSent(t *testing.T) {
-	channelID := "test-cb-sent"
+// Test that a MessageSentEvent is delivered via Subscribe (via causal history ACK)
+func TestSubscribe_MessageSent(t *testing.T) {
+	channelID := "test-sub-sent"
 
 	// Create two handles
 	handle1, err := NewReliabilityManager(channelID)
@@ -225,33 +215,19 @@ func TestCallback_OnMessageSent(t *testing.T) {
 	}
 	defer CleanupReliabilityManager(handle2)
 
-	var wg sync.WaitGroup
-	sentCalled := false
-	var sentMsgID MessageID
-	var cbMutex sync.Mutex
-
-	callbacks := Callbacks{
-		OnMessageSent: func(messageId MessageID) {
-			cbMutex.Lock()
-			sentCalled = true
-			sentMsgID = messageId
-			cbMutex.Unlock()
-			wg.Done()
-		},
-	}
-
-	// Register callback on handle1 (the original sender)
-	err = RegisterCallback(handle1, callbacks)
+	// Subscribe on handle1 (the original sender)
+	events, cancel, err := Subscribe(handle1, 4)
 	if err != nil {
-		t.Fatalf("RegisterCallback failed: %v", err)
+		t.Fatalf("Subscribe failed: %v", err)
 	}
+	defer cancel()
 
 	// Scenario: handle1 sends msg1, handle2 receives msg1,
 	// handle2 sends msg2 (acking msg1), handle1 receives msg2.
 
 	// 1. handle1 sends msg1
 	payload1 := []byte("sent test 1")
-	msgID1 := MessageID("cb-sent-1")
+	msgID1 := MessageID("sub-sent-1")
 	wrappedMsg1, err := WrapOutgoingMessage(handle1, payload1, msgID1)
 	if err != nil {
 		t.Fatalf("WrapOutgoingMessage (1) failed: %v", err)
@@ -266,36 +242,37 @@ func TestCallback_OnMessageSent(t *testing.T) {
 
 	// 3. handle2 sends msg2 (will include msg1 in causal history)
 	payload2 := []byte("sent test 2")
-	msgID2 := MessageID("cb-sent-2")
+	msgID2 := MessageID("sub-sent-2")
 	wrappedMsg2, err := WrapOutgoingMessage(handle2, payload2, msgID2)
 	if err != nil {
 		t.Fatalf("WrapOutgoingMessage (2) on handle2 failed: %v", err)
 	}
 
 	// 4. handle1 receives msg2 (should trigger ACK for msg1)
-	wg.Add(1) // Expect OnMessageSent for msg1 on handle1
 	_, _, err = UnwrapReceivedMessage(handle1, wrappedMsg2)
 	if err != nil {
 		t.Fatalf("UnwrapReceivedMessage (2) on handle1 failed: %v", err)
 	}
 
 	// Verification
-	waitTimeout(&wg, 2*time.Second, t)
-
-	cbMutex.Lock()
-	defer cbMutex.Unlock()
-	if !sentCalled {
-		t.Errorf("OnMessageSent was not called")
-	}
-	// We primarily care that msg1 was ACKed.
-	if sentMsgID != msgID1 {
-		t.Errorf("OnMessageSent called with wrong ID: got %q, want %q", sentMsgID, msgID1)
+	select {
+	case event := <-events:
+		sent, ok := event.(MessageSentEvent)
+		if !ok {
+			t.Fatalf("expected MessageSentEvent, got %T", event)
+		}
+		// We primarily care that msg1 was ACKed.
+		if sent.MessageID != msgID1 {
+			t.Errorf("MessageSentEvent has wrong ID: got %q, want %q", sent.MessageID, msgID1)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Timed out waiting for MessageSentEvent")
 	}
 }
 
-// Test OnMissingDependencies callback
-func TestCallback_OnMissingDependencies(t *testing.T) {
-	channelID := "test-cb-missing"
+// Test that a MissingDependenciesEvent is delivered via Subscribe
+func TestSubscribe_MissingDependencies(t *testing.T) {
+	channelID := "test-sub-missing"
 
 	// Use separate sender/receiver handles explicitly
 	handleSender, err := NewReliabilityManager(channelID)
@@ -310,35 +287,19 @@ func TestCallback_OnMissingDependencies(t *testing.T) {
 	}
 	defer CleanupReliabilityManager(handleReceiver)
 
-	var wg sync.WaitGroup
-	missingCalled := false
-	var missingMsgID MessageID
-	var missingDepsList []MessageID
-	var cbMutex sync.Mutex
-
-	callbacks := Callbacks{
-		OnMissingDependencies: func(messageId MessageID, missingDeps []MessageID) {
-			cbMutex.Lock()
-			missingCalled = true
-			missingMsgID = messageId
-			missingDepsList = missingDeps // Copy slice
-			cbMutex.Unlock()
-			wg.Done()
-		},
-	}
-
-	// Register callback only on the receiver handle
-	err = RegisterCallback(handleReceiver, callbacks)
+	// Subscribe only on the receiver handle
+	events, cancel, err := Subscribe(handleReceiver, 4)
 	if err != nil {
-		t.Fatalf("RegisterCallback failed: %v", err)
+		t.Fatalf("Subscribe failed: %v", err)
 	}
+	defer cancel()
 
 	// Scenario: Sender sends msg1, then sender sends msg2 (depends on msg1),
 	// then receiver receives msg2 (which hasn't seen msg1).
 
 	// 1. Sender sends msg1
 	payload1 := []byte("missing test 1")
-	msgID1 := MessageID("cb-miss-1")
+	msgID1 := MessageID("sub-miss-1")
 	_, err = WrapOutgoingMessage(handleSender, payload1, msgID1) // Assign to _
 	if err != nil {
 		t.Fatalf("WrapOutgoingMessage (1) on sender failed: %v", err)
@@ -346,98 +307,87 @@ func TestCallback_OnMissingDependencies(t *testing.T) {
 
 	// 2. Sender sends msg2 (depends on msg1)
 	payload2 := []byte("missing test 2")
-	msgID2 := MessageID("cb-miss-2")
+	msgID2 := MessageID("sub-miss-2")
 	wrappedMsg2, err := WrapOutgoingMessage(handleSender, payload2, msgID2)
 	if err != nil {
 		t.Fatalf("WrapOutgoingMessage (2) failed: %v", err)
 	}
 
 	// 3. Receiver receives msg2 (haven't seen msg1)
-	wg.Add(1) // Expect OnMissingDependencies
 	_, _, err = UnwrapReceivedMessage(handleReceiver, wrappedMsg2)
 	if err != nil {
 		t.Fatalf("UnwrapReceivedMessage (2) on receiver failed: %v", err)
 	}
 
 	// Verification
-	waitTimeout(&wg, 2*time.Second, t)
-
-	cbMutex.Lock()
-	defer cbMutex.Unlock()
-	if !missingCalled {
-		t.Errorf("OnMissingDependencies was not called")
-	}
-	if missingMsgID != msgID2 {
-		t.Errorf("OnMissingDependencies called for wrong ID: got %q, want %q", missingMsgID, msgID2)
-	}
-	foundDep := false
-	for _, dep := range missingDepsList {
-		if dep == msgID1 {
-			foundDep = true
-			break
+	select {
+	case event := <-events:
+		missing, ok := event.(MissingDependenciesEvent)
+		if !ok {
+			t.Fatalf("expected MissingDependenciesEvent, got %T", event)
 		}
-	}
-	if !foundDep {
-		t.Errorf("OnMissingDependencies did not report %q as missing, got: %v", msgID1, missingDepsList)
+		if missing.MessageID != msgID2 {
+			t.Errorf("MissingDependenciesEvent has wrong ID: got %q, want %q", missing.MessageID, msgID2)
+		}
+		foundDep := false
+		for _, dep := range missing.MissingDeps {
+			if dep == msgID1 {
+				foundDep = true
+				break
+			}
+		}
+		if !foundDep {
+			t.Errorf("MissingDependenciesEvent did not report %q as missing, got: %v", msgID1, missing.MissingDeps)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Timed out waiting for MissingDependenciesEvent")
 	}
 }
 
-// Test OnPeriodicSync callback
-func TestCallback_OnPeriodicSync(t *testing.T) {
-	channelID := "test-cb-sync"
+// Test that a PeriodicSyncEvent is delivered via Subscribe, and that
+// periodic tasks started with StartPeriodicTasksCtx stop deterministically
+// on context cancellation instead of racing CleanupReliabilityManager.
+func TestSubscribe_PeriodicSync(t *testing.T) {
+	channelID := "test-sub-sync"
 	handle, err := NewReliabilityManager(channelID)
 	if err != nil {
 		t.Fatalf("NewReliabilityManager failed: %v", err)
 	}
 	defer CleanupReliabilityManager(handle)
 
-	syncCalled := false
-	var cbMutex sync.Mutex
-	// Use a channel to signal when the callback is hit
-	syncChan := make(chan bool, 1)
-
-	callbacks := Callbacks{
-		OnPeriodicSync: func() {
-			cbMutex.Lock()
-			if !syncCalled { // Only signal the first time
-				syncCalled = true
-				syncChan <- true
-			}
-			cbMutex.Unlock()
-		},
-	}
-
-	err = RegisterCallback(handle, callbacks)
+	events, cancel, err := Subscribe(handle, 4)
 	if err != nil {
-		t.Fatalf("RegisterCallback failed: %v", err)
+		t.Fatalf("Subscribe failed: %v", err)
 	}
+	defer cancel()
 
-	// Start periodic tasks
-	err = StartPeriodicTasks(handle)
-	if err != nil {
-		t.Fatalf("StartPeriodicTasks failed: %v", err)
+	ctx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+
+	if err := StartPeriodicTasksCtx(ctx, handle); err != nil {
+		t.Fatalf("StartPeriodicTasksCtx failed: %v", err)
 	}
 
 	// --- Verification ---
-	// Wait for the periodic sync callback with a timeout (needs to be longer than sync interval)
+	// Wait for a periodic sync event with a timeout (needs to be longer than sync interval)
 	select {
-	case <-syncChan:
-		// Success
+	case event := <-events:
+		if _, ok := event.(PeriodicSyncEvent); !ok {
+			t.Fatalf("expected PeriodicSyncEvent, got %T", event)
+		}
 	case <-time.After(10 * time.Second):
-		t.Errorf("Timed out waiting for OnPeriodicSync callback")
+		t.Errorf("Timed out waiting for PeriodicSyncEvent")
 	}
 
-	cbMutex.Lock()
-	defer cbMutex.Unlock()
-	if !syncCalled {
-		// This might happen if the timeout was too short
-		t.Logf("Warning: OnPeriodicSync might not have been called within the test timeout")
-	}
+	// Cancelling ctx must stop the periodic tasks deterministically, without
+	// relying on CleanupReliabilityManager racing the sync goroutine.
+	cancelTasks()
 }
 
-// Combined Test for multiple callbacks
-func TestCallbacks_Combined(t *testing.T) {
-	channelID := "test-cb-combined"
+// Combined test for multiple concurrent subscribers, each on a different
+// handle, receiving only the events meant for them.
+func TestSubscribe_Combined(t *testing.T) {
+	channelID := "test-sub-combined"
 
 	// Create sender and receiver handles
 	handleSender, err := NewReliabilityManager(channelID)
@@ -452,73 +402,22 @@ func TestCallbacks_Combined(t *testing.T) {
 	}
 	defer CleanupReliabilityManager(handleReceiver)
 
-	// Channels for synchronization
-	readyChan1 := make(chan bool, 1)
-	sentChan1 := make(chan bool, 1)
-	missingChan := make(chan []MessageID, 1)
-
-	// Use maps for verification
-	receivedReady := make(map[MessageID]bool)
-	receivedSent := make(map[MessageID]bool)
-	var cbMutex sync.Mutex
-
-	callbacksReceiver := Callbacks{
-		OnMessageReady: func(messageId MessageID) {
-			cbMutex.Lock()
-			receivedReady[messageId] = true
-			cbMutex.Unlock()
-			if messageId == "cb-comb-1" {
-				// Use non-blocking send
-				select {
-				case readyChan1 <- true:
-				default:
-				}
-			}
-		},
-		OnMessageSent: func(messageId MessageID) {
-			// This callback is registered on Receiver, but Sent events
-			// are typically relevant to the Sender. We don't expect this.
-			t.Errorf("Unexpected OnMessageSent call on Receiver for %s", messageId)
-		},
-		OnMissingDependencies: func(messageId MessageID, missingDeps []MessageID) {
-			// This callback is registered on Receiver, used for handleReceiver2 below
-		},
-	}
-
-	callbacksSender := Callbacks{
-		OnMessageReady: func(messageId MessageID) {
-			// Not expected on sender in this test flow
-		},
-		OnMessageSent: func(messageId MessageID) {
-			cbMutex.Lock()
-			receivedSent[messageId] = true
-			cbMutex.Unlock()
-			if messageId == "cb-comb-1" {
-				select {
-				case sentChan1 <- true:
-				default:
-				}
-			}
-		},
-		OnMissingDependencies: func(messageId MessageID, missingDeps []MessageID) {
-			// Not expected on sender
-		},
-	}
-
-	// Register callbacks
-	err = RegisterCallback(handleReceiver, callbacksReceiver)
+	receiverEvents, cancelReceiver, err := Subscribe(handleReceiver, 8)
 	if err != nil {
-		t.Fatalf("RegisterCallback (Receiver) failed: %v", err)
+		t.Fatalf("Subscribe (Receiver) failed: %v", err)
 	}
-	err = RegisterCallback(handleSender, callbacksSender)
+	defer cancelReceiver()
+
+	senderEvents, cancelSender, err := Subscribe(handleSender, 8)
 	if err != nil {
-		t.Fatalf("RegisterCallback (Sender) failed: %v", err)
+		t.Fatalf("Subscribe (Sender) failed: %v", err)
 	}
+	defer cancelSender()
 
 	// --- Test Scenario ---
-	msgID1 := MessageID("cb-comb-1")
-	msgID2 := MessageID("cb-comb-2")
-	msgID3 := MessageID("cb-comb-3")
+	msgID1 := MessageID("sub-comb-1")
+	msgID2 := MessageID("sub-comb-2")
+	msgID3 := MessageID("sub-comb-3")
 	payload1 := []byte("combined test 1")
 	payload2 := []byte("combined test 2")
 	payload3 := []byte("combined test 3")
@@ -553,27 +452,18 @@ func TestCallbacks_Combined(t *testing.T) {
 		t.Fatalf("WrapOutgoingMessage (3) failed: %v", err)
 	}
 
-	// 6. Create Receiver2, register missing deps callback
+	// 6. Create Receiver2, subscribe for its missing-deps events
 	handleReceiver2, err := NewReliabilityManager(channelID)
 	if err != nil {
 		t.Fatalf("NewReliabilityManager (Receiver2) failed: %v", err)
 	}
 	defer CleanupReliabilityManager(handleReceiver2)
 
-	callbacksReceiver2 := Callbacks{
-		OnMissingDependencies: func(messageId MessageID, missingDeps []MessageID) {
-			if messageId == msgID3 {
-				select {
-				case missingChan <- missingDeps:
-				default:
-				}
-			}
-		},
-	}
-	err = RegisterCallback(handleReceiver2, callbacksReceiver2)
+	receiver2Events, cancelReceiver2, err := Subscribe(handleReceiver2, 8)
 	if err != nil {
-		t.Fatalf("RegisterCallback (Receiver2) failed: %v", err)
+		t.Fatalf("Subscribe (Receiver2) failed: %v", err)
 	}
+	defer cancelReceiver2()
 
 	// 7. Receiver2 receives msg3 (should report missing msg1, msg2)
 	_, _, err = UnwrapReceivedMessage(handleReceiver2, wrappedMsg3)
@@ -582,80 +472,86 @@ func TestCallbacks_Combined(t *testing.T) {
 	}
 
 	// --- Verification ---
-	timeout := 5 * time.Second
-	expectedReady1 := false
-	expectedSent1 := false
+	gotReady1 := false
+	gotSent1 := false
 	var reportedMissingDeps []MessageID
-	missingDepsReceived := false
+	gotMissingDeps := false
 
-	receivedCount := 0
-	expectedCount := 3 // ready1, sent1, missingDeps
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
-
-	for receivedCount < expectedCount {
+	timeout := time.After(5 * time.Second)
+	for !gotReady1 || !gotSent1 || !gotMissingDeps {
 		select {
-		case <-readyChan1:
-			if !expectedReady1 { // Avoid double counting if signaled twice
-				expectedReady1 = true
-				receivedCount++
+		case event := <-receiverEvents:
+			switch e := event.(type) {
+			case MessageReadyEvent:
+				if e.MessageID == msgID1 {
+					gotReady1 = true
+				}
+			case MessageSentEvent:
+				t.Errorf("Unexpected MessageSentEvent on Receiver for %s", e.MessageID)
 			}
-		case <-sentChan1:
-			if !expectedSent1 {
-				expectedSent1 = true
-				receivedCount++
+		case event := <-senderEvents:
+			switch e := event.(type) {
+			case MessageSentEvent:
+				if e.MessageID == msgID1 {
+					gotSent1 = true
+				}
+			case MessageReadyEvent:
+				// Not expected on the sender in this test flow.
 			}
-		case deps := <-missingChan:
-			if !missingDepsReceived {
-				reportedMissingDeps = deps
-				missingDepsReceived = true
-				receivedCount++
+		case event := <-receiver2Events:
+			if e, ok := event.(MissingDependenciesEvent); ok && e.MessageID == msgID3 {
+				reportedMissingDeps = e.MissingDeps
+				gotMissingDeps = true
 			}
-		case <-timer.C:
-			t.Fatalf("Timed out waiting for combined callbacks (received %d out of %d)", receivedCount, expectedCount)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for combined events (ready1=%v sent1=%v missingDeps=%v)", gotReady1, gotSent1, gotMissingDeps)
 		}
 	}
 
-	// Check results
-	cbMutex.Lock()
-	defer cbMutex.Unlock()
+	foundDep1, foundDep2 := false, false
+	for _, dep := range reportedMissingDeps {
+		if dep == msgID1 {
+			foundDep1 = true
+		}
+		if dep == msgID2 {
+			foundDep2 = true
+		}
+	}
+	if !foundDep1 || !foundDep2 {
+		t.Errorf("MissingDependenciesEvent for %s reported wrong deps: got %v, want %s and %s", msgID3, reportedMissingDeps, msgID1, msgID2)
+	}
+}
 
-	if !expectedReady1 || !receivedReady[msgID1] {
-		t.Errorf("OnMessageReady not called/verified for %s", msgID1)
+// Test that a nil handle is reported as ErrInvalidHandle by the single-message
+// wrapper functions, RegisterCallback and StartPeriodicTasks
+func TestErrors_InvalidHandle(t *testing.T) {
+	if err := ResetReliabilityManager(nil); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("ResetReliabilityManager(nil): expected ErrInvalidHandle, got %v", err)
 	}
-	if !expectedSent1 || !receivedSent[msgID1] {
-		t.Errorf("OnMessageSent not called/verified for %s", msgID1)
+	if _, err := WrapOutgoingMessage(nil, []byte("x"), "id"); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("WrapOutgoingMessage(nil): expected ErrInvalidHandle, got %v", err)
 	}
-	if !missingDepsReceived {
-		t.Errorf("OnMissingDependencies not called/verified for %s", msgID3)
-	} else {
-		foundDep1 := false
-		foundDep2 := false
-		for _, dep := range reportedMissingDeps {
-			if dep == msgID1 {
-				foundDep1 = true
-			}
-			if dep == msgID2 {
-				foundDep2 = true
-			}
-		}
-		if !foundDep1 || !foundDep2 {
-			t.Errorf("OnMissingDependencies for %s reported wrong deps: got %v, want %s and %s", msgID3, reportedMissingDeps, msgID1, msgID2)
-		}
+	if _, _, err := UnwrapReceivedMessage(nil, []byte("x")); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("UnwrapReceivedMessage(nil): expected ErrInvalidHandle, got %v", err)
+	}
+	if err := MarkDependenciesMet(nil, []MessageID{"a"}); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("MarkDependenciesMet(nil): expected ErrInvalidHandle, got %v", err)
+	}
+	if err := RegisterCallback(nil, Callbacks{}); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("RegisterCallback(nil): expected ErrInvalidHandle, got %v", err)
+	}
+	if err := StartPeriodicTasks(nil); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("StartPeriodicTasks(nil): expected ErrInvalidHandle, got %v", err)
 	}
 }
 
-// Helper function to wait for WaitGroup with a timeout
-func waitTimeout(wg *sync.WaitGroup, timeout time.Duration, t *testing.T) {
-	c := make(chan struct{})
-	go func() {
-		defer close(c)
-		wg.Wait()
-	}()
-	select {
-	case <-c:
-		// Completed normally
-	case <-time.After(timeout):
-		t.Fatalf("Timed out waiting for callbacks")
+// Test that SDSError.Is distinguishes categories regardless of message
+func TestSDSError_Is(t *testing.T) {
+	err := newSDSError(ErrCodeUnknownMessage, "no such message: abc123")
+	if !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("expected errors.Is to match ErrUnknownMessage, got %v", err)
+	}
+	if errors.Is(err, ErrSerialization) {
+		t.Errorf("expected errors.Is to NOT match ErrSerialization, got %v", err)
 	}
 }
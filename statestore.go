@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore persists a reliability manager's serialized state so a peer can
+// survive a restart without losing causal history and re-syncing the whole
+// network. Save/Load operate on full snapshots; AppendLog is for backends
+// that want a cheaper durability path between snapshots (e.g. a WAL).
+type StateStore interface {
+	Save(channelID string, snapshot []byte) error
+	Load(channelID string) ([]byte, error)
+	AppendLog(channelID string, entry []byte) error
+}
+
+// FileStateStore persists one snapshot file and one append-only log file per
+// channel underneath Dir. It is the simplest backend and the right default
+// for a single-process deployment.
+type FileStateStore struct {
+	Dir string
+}
+
+func (s FileStateStore) snapshotPath(channelID string) string {
+	return filepath.Join(s.Dir, channelID+".snapshot")
+}
+
+func (s FileStateStore) logPath(channelID string) string {
+	return filepath.Join(s.Dir, channelID+".log")
+}
+
+func (s FileStateStore) Save(channelID string, snapshot []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("sds: creating state dir: %w", err)
+	}
+	tmp := s.snapshotPath(channelID) + ".tmp"
+	if err := os.WriteFile(tmp, snapshot, 0o644); err != nil {
+		return fmt.Errorf("sds: writing snapshot: %w", err)
+	}
+	return os.Rename(tmp, s.snapshotPath(channelID))
+}
+
+func (s FileStateStore) Load(channelID string) ([]byte, error) {
+	data, err := os.ReadFile(s.snapshotPath(channelID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s FileStateStore) AppendLog(channelID string, entry []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("sds: creating state dir: %w", err)
+	}
+	f, err := os.OpenFile(s.logPath(channelID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("sds: opening log: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(entry, '\n'))
+	return err
+}
+
+// RedisStateStore persists snapshots and log entries as Redis keys, suitable
+// for peers that want crash-recovery state shared across a fleet rather than
+// kept on local disk.
+type RedisStateStore struct {
+	Client *redis.Client
+	// KeyPrefix namespaces keys in a shared Redis instance, e.g. "sds:".
+	KeyPrefix string
+}
+
+func (s RedisStateStore) snapshotKey(channelID string) string {
+	return s.KeyPrefix + channelID + ":snapshot"
+}
+
+func (s RedisStateStore) logKey(channelID string) string {
+	return s.KeyPrefix + channelID + ":log"
+}
+
+func (s RedisStateStore) Save(channelID string, snapshot []byte) error {
+	return s.Client.Set(context.Background(), s.snapshotKey(channelID), snapshot, 0).Err()
+}
+
+func (s RedisStateStore) Load(channelID string) ([]byte, error) {
+	data, err := s.Client.Get(context.Background(), s.snapshotKey(channelID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s RedisStateStore) AppendLog(channelID string, entry []byte) error {
+	return s.Client.RPush(context.Background(), s.logKey(channelID), entry).Err()
+}
+
+// KVClient is the minimal surface StateStore needs from a generic KV system
+// such as etcd or Consul, so KVStateStore isn't tied to either client.
+type KVClient interface {
+	Put(ctx context.Context, key string, value []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// KVStateStore adapts any KVClient (etcd, Consul, ...) into a StateStore.
+// Log entries are appended as distinct keys since most KV stores have no
+// native append primitive.
+type KVStateStore struct {
+	Client KVClient
+	Prefix string
+}
+
+func (s KVStateStore) snapshotKey(channelID string) string {
+	return s.Prefix + channelID + "/snapshot"
+}
+
+func (s KVStateStore) Save(channelID string, snapshot []byte) error {
+	return s.Client.Put(context.Background(), s.snapshotKey(channelID), snapshot)
+}
+
+func (s KVStateStore) Load(channelID string) ([]byte, error) {
+	return s.Client.Get(context.Background(), s.snapshotKey(channelID))
+}
+
+func (s KVStateStore) AppendLog(channelID string, entry []byte) error {
+	key := fmt.Sprintf("%s%s/log/%d", s.Prefix, channelID, time.Now().UnixNano())
+	return s.Client.Put(context.Background(), key, entry)
+}
+
+// defaultSnapshotInterval is used when WithStateStore is given a zero
+// interval.
+const defaultSnapshotInterval = 30 * time.Second
+
+// WithStateStore configures the manager to reload its state from store on
+// startup and snapshot to it on the given interval and on
+// CleanupReliabilityManager. A zero interval falls back to
+// defaultSnapshotInterval.
+func WithStateStore(store StateStore, snapshotInterval time.Duration) Option {
+	return func(o *managerOptions) {
+		o.stateStore = store
+		o.snapshotInterval = snapshotInterval
+	}
+}
+
+// snapshotterState tracks everything CleanupReliabilityManager needs to stop
+// a handle's background snapshot loop and take one last snapshot.
+type snapshotterState struct {
+	channelID string
+	store     StateStore
+	stop      chan struct{}
+	// done is closed by the snapshot loop goroutine right before it
+	// returns, so stopSnapshotLoop can wait for any in-flight
+	// tick-triggered snapshotState call to finish before taking its own
+	// final snapshot, rather than racing it.
+	done chan struct{}
+}
+
+// snapshotters tracks the background snapshot loop started for a handle so
+// CleanupReliabilityManager can stop it and take one last snapshot.
+var (
+	snapshottersMu sync.Mutex
+	snapshotters   = make(map[ReliabilityManagerHandle]*snapshotterState)
+)
+
+// restoreState reloads a previously persisted snapshot, if any, into the
+// Nim-side manager before it starts processing messages, so long-running
+// peers survive restarts without flooding the network with re-sync traffic.
+func restoreState(handle ReliabilityManagerHandle, channelID string, store StateStore) error {
+	snapshot, err := store.Load(channelID)
+	if err != nil {
+		return fmt.Errorf("sds: loading persisted state: %w", err)
+	}
+	if len(snapshot) == 0 {
+		return nil
+	}
+	return loadStateIntoManager(handle, snapshot)
+}
+
+// startSnapshotLoop periodically serializes the manager's state and saves it
+// via store, until stopped by stopSnapshotLoop.
+func startSnapshotLoop(handle ReliabilityManagerHandle, channelID string, store StateStore, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	snapshottersMu.Lock()
+	snapshotters[handle] = &snapshotterState{channelID: channelID, store: store, stop: stop, done: done}
+	snapshottersMu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := snapshotState(handle, channelID, store); err != nil {
+					fmt.Printf("sds: periodic snapshot for %q failed: %v\n", channelID, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopSnapshotLoop stops the background snapshot loop for handle, if any,
+// and takes one final snapshot so shutdown doesn't lose state accumulated
+// since the last tick.
+func stopSnapshotLoop(handle ReliabilityManagerHandle) {
+	snapshottersMu.Lock()
+	state, ok := snapshotters[handle]
+	delete(snapshotters, handle)
+	snapshottersMu.Unlock()
+
+	if !ok {
+		return
+	}
+	close(state.stop)
+	<-state.done // wait for any in-flight tick's snapshotState to finish first
+	if err := snapshotState(handle, state.channelID, state.store); err != nil {
+		fmt.Printf("sds: final snapshot for %q failed: %v\n", state.channelID, err)
+	}
+}
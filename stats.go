@@ -0,0 +1,164 @@
+package main
+
+/*
+#include "bindings/bindings.h"
+
+// Each cgo file gets its own translation unit, so the callFreeC* helpers in
+// sds_wrapper.go's preamble aren't visible here; redeclare what this file
+// needs.
+static void callFreeCWrapResult(CWrapResult res) { FreeCWrapResult(res); }
+static void callFreeCManagerStatsResult(CManagerStatsResult res) { FreeCManagerStatsResult(res); }
+*/
+import "C"
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// ManagerStats is a point-in-time snapshot of a ReliabilityManager's
+// internal state, modeled on grpc-go's channelz: enough for an operator (or
+// a test) to assert on what the manager is actually doing instead of
+// inferring it from callback/event timing.
+type ManagerStats struct {
+	OutgoingBufferLen     int
+	IncomingBufferLen     int
+	CausalHistoryCount    int
+	DependencyCounts      map[MessageID]int
+	PendingMissingDepSets int
+	LastPeriodicSync      time.Time
+	MessagesWrapped       uint64
+	MessagesUnwrapped     uint64
+	MessagesAcknowledged  uint64
+	MessagesRetransmitted uint64
+}
+
+// PendingMessage describes an outgoing message still awaiting ACK.
+type PendingMessage struct {
+	MessageID   MessageID
+	Attempts    int
+	FirstSentAt time.Time
+	// NextRetransmitAt is this manager's ReliabilityConfig schedule applied
+	// to Attempts/FirstSentAt: the cumulative sum of nextRetransmitDelay for
+	// attempts 0..Attempts, absent jitter, i.e. when the Nim side is
+	// expected to retry next. It is a prediction, not a value read back
+	// from Nim.
+	NextRetransmitAt time.Time
+}
+
+// GetManagerStats returns a snapshot of handle's internal reliability
+// state. The per-message dependency counts are returned as length-prefixed
+// frames (see packFrames/unpackFrames in batch.go) so the fixed-size C
+// struct doesn't need to bound the number of tracked messages.
+func GetManagerStats(handle ReliabilityManagerHandle) (ManagerStats, error) {
+	if handle == nil {
+		return ManagerStats{}, ErrInvalidHandle
+	}
+
+	cStats := C.GetManagerStats(unsafe.Pointer(handle))
+	if !cStats.base_result.is_ok {
+		errMsg := C.GoString(cStats.base_result.error_message)
+		errCode := errorCodeFromC(int(cStats.base_result.error_code))
+		C.callFreeCManagerStatsResult(cStats)
+		return ManagerStats{}, newSDSError(errCode, errMsg)
+	}
+
+	packed := C.GoBytes(unsafe.Pointer(cStats.dependency_counts), C.int(cStats.dependency_counts_len))
+	stats := ManagerStats{
+		OutgoingBufferLen:     int(cStats.outgoing_buffer_len),
+		IncomingBufferLen:     int(cStats.incoming_buffer_len),
+		CausalHistoryCount:    int(cStats.causal_history_count),
+		PendingMissingDepSets: int(cStats.pending_missing_dep_sets),
+		LastPeriodicSync:      time.UnixMilli(int64(cStats.last_periodic_sync_unix_ms)),
+		MessagesWrapped:       uint64(cStats.messages_wrapped),
+		MessagesUnwrapped:     uint64(cStats.messages_unwrapped),
+		MessagesAcknowledged:  uint64(cStats.messages_acknowledged),
+		MessagesRetransmitted: uint64(cStats.messages_retransmitted),
+	}
+	C.callFreeCManagerStatsResult(cStats)
+
+	depCounts, err := parseDependencyCounts(packed)
+	if err != nil {
+		return ManagerStats{}, err
+	}
+	stats.DependencyCounts = depCounts
+	return stats, nil
+}
+
+// parseDependencyCounts decodes the packed [id, count]... frames
+// GetManagerStats gets back from the Nim side into a map, kept as its own
+// function so the frame-parsing (including the malformed-input paths) is
+// testable without a live handle.
+func parseDependencyCounts(packed []byte) (map[MessageID]int, error) {
+	frames, err := unpackFrames(packed)
+	if err != nil {
+		return nil, fmt.Errorf("sds: malformed dependency counts: %w", err)
+	}
+	counts := make(map[MessageID]int, len(frames)/2)
+	for i := 0; i+1 < len(frames); i += 2 {
+		id := MessageID(frames[i])
+		if len(frames[i+1]) != 4 {
+			return nil, fmt.Errorf("sds: malformed dependency count for %s", id)
+		}
+		counts[id] = int(binary.LittleEndian.Uint32(frames[i+1]))
+	}
+	return counts, nil
+}
+
+// ListPendingMessages returns the outgoing messages on handle that are
+// still awaiting acknowledgment, in the order the manager tracks them.
+func ListPendingMessages(handle ReliabilityManagerHandle) ([]PendingMessage, error) {
+	if handle == nil {
+		return nil, ErrInvalidHandle
+	}
+
+	cResult := C.ListPendingMessages(unsafe.Pointer(handle))
+	if !cResult.base_result.is_ok {
+		errMsg := C.GoString(cResult.base_result.error_message)
+		errCode := errorCodeFromC(int(cResult.base_result.error_code))
+		C.callFreeCWrapResult(cResult)
+		return nil, newSDSError(errCode, errMsg)
+	}
+
+	packed := C.GoBytes(unsafe.Pointer(cResult.message), C.int(cResult.message_len))
+	C.callFreeCWrapResult(cResult)
+
+	return parsePendingMessages(packed, reliabilityConfigFor(handle))
+}
+
+// parsePendingMessages decodes the packed [id, attempts, firstSentAt]...
+// frames ListPendingMessages gets back from the Nim side, kept as its own
+// function so the frame-parsing (including the malformed-input paths) is
+// testable without a live handle. cfg is used to predict NextRetransmitAt
+// for each entry via nextRetransmitDelay, with jitter stripped out so the
+// prediction is deterministic.
+func parsePendingMessages(packed []byte, cfg ReliabilityConfig) ([]PendingMessage, error) {
+	frames, err := unpackFrames(packed)
+	if err != nil {
+		return nil, fmt.Errorf("sds: malformed pending message list: %w", err)
+	}
+	noJitter := cfg
+	noJitter.JitterFraction = 0
+
+	pending := make([]PendingMessage, 0, len(frames)/3)
+	for i := 0; i+2 < len(frames); i += 3 {
+		id := MessageID(frames[i])
+		if len(frames[i+1]) != 4 || len(frames[i+2]) != 8 {
+			return nil, fmt.Errorf("sds: malformed pending message entry for %s", id)
+		}
+		attempts := int(binary.LittleEndian.Uint32(frames[i+1]))
+		firstSentAt := time.UnixMilli(int64(binary.LittleEndian.Uint64(frames[i+2])))
+		nextRetransmitAt := firstSentAt
+		for a := 0; a <= attempts; a++ {
+			nextRetransmitAt = nextRetransmitAt.Add(nextRetransmitDelay(noJitter, a))
+		}
+		pending = append(pending, PendingMessage{
+			MessageID:        id,
+			Attempts:         attempts,
+			FirstSentAt:      firstSentAt,
+			NextRetransmitAt: nextRetransmitAt,
+		})
+	}
+	return pending, nil
+}
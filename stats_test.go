@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// TestParseDependencyCounts_Valid checks the happy path: a packed
+// [id, count]... stream decodes into the expected map.
+func TestParseDependencyCounts_Valid(t *testing.T) {
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, 3)
+	packed := packFrames([][]byte{[]byte("m1"), count})
+
+	got, err := parseDependencyCounts(packed)
+	if err != nil {
+		t.Fatalf("parseDependencyCounts failed: %v", err)
+	}
+	if got["m1"] != 3 {
+		t.Errorf("got DependencyCounts[m1] = %d, want 3", got["m1"])
+	}
+}
+
+// TestParseDependencyCounts_Malformed checks that a truncated count field (or
+// any other malformed frame) is reported as an error instead of panicking.
+func TestParseDependencyCounts_Malformed(t *testing.T) {
+	cases := map[string][]byte{
+		"short count field": packFrames([][]byte{[]byte("m1"), {1, 2}}),
+		"truncated frame body": func() []byte {
+			packed := packFrames([][]byte{[]byte("m1")})
+			return packed[:len(packed)-1]
+		}(),
+	}
+	for name, packed := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseDependencyCounts(packed); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+// TestParsePendingMessages_Valid checks the happy path: a packed
+// [id, attempts, firstSentAt]... stream decodes into the expected slice.
+func TestParsePendingMessages_Valid(t *testing.T) {
+	attempts := make([]byte, 4)
+	binary.LittleEndian.PutUint32(attempts, 2)
+	firstSentAt := make([]byte, 8)
+	sentTime := time.UnixMilli(1700000000000)
+	binary.LittleEndian.PutUint64(firstSentAt, uint64(sentTime.UnixMilli()))
+	packed := packFrames([][]byte{[]byte("m1"), attempts, firstSentAt})
+
+	cfg := ReliabilityConfig{BaseDelay: time.Second, MaxDelay: 30 * time.Second, MaxAttempts: 8}
+	got, err := parsePendingMessages(packed, cfg)
+	if err != nil {
+		t.Fatalf("parsePendingMessages failed: %v", err)
+	}
+	wantNextRetransmitAt := sentTime
+	for a := 0; a <= 2; a++ {
+		wantNextRetransmitAt = wantNextRetransmitAt.Add(nextRetransmitDelay(cfg, a))
+	}
+	if len(got) != 1 || got[0].MessageID != "m1" || got[0].Attempts != 2 || !got[0].FirstSentAt.Equal(sentTime) || !got[0].NextRetransmitAt.Equal(wantNextRetransmitAt) {
+		t.Errorf("got %+v, want [{m1 2 %v %v}]", got, sentTime, wantNextRetransmitAt)
+	}
+}
+
+// TestParsePendingMessages_Malformed checks that short attempts/firstSentAt
+// fields are reported as an error instead of panicking on the
+// binary.LittleEndian.Uint32/Uint64 calls.
+func TestParsePendingMessages_Malformed(t *testing.T) {
+	cases := map[string][]byte{
+		"short attempts field":    packFrames([][]byte{[]byte("m1"), {1, 2}, make([]byte, 8)}),
+		"short firstSentAt field": packFrames([][]byte{[]byte("m1"), make([]byte, 4), {1, 2}}),
+		"truncated frame body": func() []byte {
+			packed := packFrames([][]byte{[]byte("m1"), make([]byte, 4), make([]byte, 8)})
+			return packed[:len(packed)-1]
+		}(),
+	}
+	for name, packed := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parsePendingMessages(packed, defaultReliabilityConfig); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/waku-org/nim-sds"
+
+// handleTelemetry holds the tracer, meter, and bound instruments a single
+// ReliabilityManager handle emits through. Each handle gets its own,
+// built from whatever WithOTLPExporter/WithOTLPMetricReader Options it was
+// constructed with; a handle with neither configured falls back to
+// defaultTelemetry, built from the package's default (global) providers.
+// Keeping these per-handle, rather than calling otel.SetTracerProvider, means
+// configuring exporters for one manager never changes what another manager
+// in the same process emits.
+type handleTelemetry struct {
+	tracer trace.Tracer
+	meter  metric.Meter
+
+	messagesWrappedCounter       metric.Int64Counter
+	messagesUnwrappedCounter     metric.Int64Counter
+	messagesReadyCounter         metric.Int64Counter
+	messagesSentCounter          metric.Int64Counter
+	dependenciesMissingCtr       metric.Int64Counter
+	wrapDurationHist             metric.Float64Histogram
+	unwrapDurationHist           metric.Float64Histogram
+	messagesRetransmittedCounter metric.Int64Counter
+}
+
+// newHandleTelemetry binds the package's fixed set of instruments against
+// tracer/meter.
+func newHandleTelemetry(tracer trace.Tracer, meter metric.Meter) (*handleTelemetry, error) {
+	t := &handleTelemetry{tracer: tracer, meter: meter}
+
+	var err error
+	if t.messagesWrappedCounter, err = meter.Int64Counter("sds.messages.wrapped"); err != nil {
+		return nil, err
+	}
+	if t.messagesUnwrappedCounter, err = meter.Int64Counter("sds.messages.unwrapped"); err != nil {
+		return nil, err
+	}
+	if t.messagesReadyCounter, err = meter.Int64Counter("sds.messages.ready"); err != nil {
+		return nil, err
+	}
+	if t.messagesSentCounter, err = meter.Int64Counter("sds.messages.sent"); err != nil {
+		return nil, err
+	}
+	if t.dependenciesMissingCtr, err = meter.Int64Counter("sds.dependencies.missing"); err != nil {
+		return nil, err
+	}
+	if t.wrapDurationHist, err = meter.Float64Histogram("sds.wrap.duration"); err != nil {
+		return nil, err
+	}
+	if t.unwrapDurationHist, err = meter.Float64Histogram("sds.unwrap.duration"); err != nil {
+		return nil, err
+	}
+	if t.messagesRetransmittedCounter, err = meter.Int64Counter("sds.messages.retransmitted"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// defaultTelemetry is what every handle uses until/unless it configures its
+// own exporters via WithOTLPExporter/WithOTLPMetricReader. It is built once
+// from the package's default tracer/meter (i.e. whatever global providers,
+// if any, the host process has registered), never reassigned afterwards.
+var defaultTelemetry *handleTelemetry
+
+func init() {
+	t, err := newHandleTelemetry(otel.Tracer(instrumentationName), otel.Meter(instrumentationName))
+	if err != nil {
+		panic(err)
+	}
+	defaultTelemetry = t
+}
+
+// handleTelemetryMu guards handleTelemetryByHandle, keyed the same way as
+// the other per-handle maps in this package (handleCodecs, legacyCallbackCancel).
+var (
+	handleTelemetryMu       sync.RWMutex
+	handleTelemetryByHandle = make(map[ReliabilityManagerHandle]*handleTelemetry)
+)
+
+// registerTelemetry associates t with handle. Called once, from
+// NewReliabilityManagerWithOptions, after the handle's own tracer/meter (if
+// any were configured) have been built.
+func registerTelemetry(handle ReliabilityManagerHandle, t *handleTelemetry) {
+	handleTelemetryMu.Lock()
+	handleTelemetryByHandle[handle] = t
+	handleTelemetryMu.Unlock()
+}
+
+// telemetryFor returns the handleTelemetry configured for handle, or
+// defaultTelemetry if none was registered (or handle is nil, e.g. a span
+// started before a handle exists).
+func telemetryFor(handle ReliabilityManagerHandle) *handleTelemetry {
+	handleTelemetryMu.RLock()
+	defer handleTelemetryMu.RUnlock()
+	if t, ok := handleTelemetryByHandle[handle]; ok {
+		return t
+	}
+	return defaultTelemetry
+}
+
+// unregisterTelemetry removes the handleTelemetry tracked for handle.
+func unregisterTelemetry(handle ReliabilityManagerHandle) {
+	handleTelemetryMu.Lock()
+	delete(handleTelemetryByHandle, handle)
+	handleTelemetryMu.Unlock()
+}
+
+// handleChannelIDsMu guards handleChannelIDs, keyed the same way as the
+// other per-handle maps in this package. It's kept separate from
+// handleTelemetry itself because defaultTelemetry is shared across every
+// handle that didn't configure its own exporters, so it can't hold a single
+// handle's channel ID.
+var (
+	handleChannelIDsMu sync.RWMutex
+	handleChannelIDs   = make(map[ReliabilityManagerHandle]string)
+)
+
+// registerChannelID associates channelID with handle, so spans started for
+// handle can carry it via channelIDAttr. Called once, from
+// NewReliabilityManagerWithOptions.
+func registerChannelID(handle ReliabilityManagerHandle, channelID string) {
+	handleChannelIDsMu.Lock()
+	handleChannelIDs[handle] = channelID
+	handleChannelIDsMu.Unlock()
+}
+
+// channelIDFor returns the channel ID registered for handle, or "" if none
+// was (e.g. a span started before a handle exists).
+func channelIDFor(handle ReliabilityManagerHandle) string {
+	handleChannelIDsMu.RLock()
+	defer handleChannelIDsMu.RUnlock()
+	return handleChannelIDs[handle]
+}
+
+// unregisterChannelID removes the channel ID tracked for handle.
+func unregisterChannelID(handle ReliabilityManagerHandle) {
+	handleChannelIDsMu.Lock()
+	delete(handleChannelIDs, handle)
+	handleChannelIDsMu.Unlock()
+}
+
+// managerOptions holds the configuration assembled from Option values passed
+// to NewReliabilityManagerWithOptions.
+type managerOptions struct {
+	otlpExporter      otlptrace.Client
+	otlpMetricReader  sdkmetric.Reader
+	stateStore        StateStore
+	snapshotInterval  time.Duration
+	reliabilityConfig *ReliabilityConfig
+	codec             Codec
+}
+
+// Option configures a ReliabilityManager at construction time.
+type Option func(*managerOptions)
+
+// WithOTLPExporter wires an OTLP trace exporter into this handle's own
+// tracer provider, so spans emitted for this handle are shipped to the
+// configured collector. It does not affect any other handle, nor the
+// process-wide global tracer provider.
+func WithOTLPExporter(client otlptrace.Client) Option {
+	return func(o *managerOptions) {
+		o.otlpExporter = client
+	}
+}
+
+// WithOTLPMetricReader wires a metric reader (e.g.
+// sdkmetric.NewPeriodicReader wrapping an otlpmetricgrpc/otlpmetrichttp
+// exporter) into this handle's own meter provider, so the counters and
+// histograms in this file are exported for this handle. As with
+// WithOTLPExporter, this is scoped to the handle it's passed to.
+func WithOTLPMetricReader(reader sdkmetric.Reader) Option {
+	return func(o *managerOptions) {
+		o.otlpMetricReader = reader
+	}
+}
+
+// buildHandleTelemetry constructs the handleTelemetry a new manager should
+// use: its own tracer/meter (and provider) for whichever of
+// WithOTLPExporter/WithOTLPMetricReader were configured, falling back to the
+// package default for the other.
+func buildHandleTelemetry(opts managerOptions) (*handleTelemetry, error) {
+	tracer := defaultTelemetry.tracer
+	if opts.otlpExporter != nil {
+		exporter := otlptrace.NewUnstarted(opts.otlpExporter)
+		if err := exporter.Start(context.Background()); err != nil {
+			return nil, fmt.Errorf("starting OTLP trace exporter: %w", err)
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+		tracer = tp.Tracer(instrumentationName)
+	}
+
+	meter := defaultTelemetry.meter
+	if opts.otlpMetricReader != nil {
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(opts.otlpMetricReader))
+		meter = mp.Meter(instrumentationName)
+	}
+
+	if tracer == defaultTelemetry.tracer && meter == defaultTelemetry.meter {
+		return defaultTelemetry, nil
+	}
+	return newHandleTelemetry(tracer, meter)
+}
+
+// endSpanWithError records err on span, if non-nil, and always ends the span.
+func endSpanWithError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func channelIDAttr(channelID string) attribute.KeyValue {
+	return attribute.String("sds.channel_id", channelID)
+}
+
+func messageIDAttr(messageID MessageID) attribute.KeyValue {
+	return attribute.String("sds.message_id", string(messageID))
+}
+
+func missingDepsCountAttr(n int) attribute.KeyValue {
+	return attribute.Int("sds.missing_deps_count", n)
+}
+
+// recordCallbackEvent starts and immediately ends a span named name, on
+// handle's configured tracer, describing a relay callback so that periodic
+// sync, ready, and sent
+// notifications show up in traces even though they originate from the
+// Nim-owned relay thread rather than a user span.
+func recordCallbackEvent(ctx context.Context, handle ReliabilityManagerHandle, name string, attrs ...attribute.KeyValue) {
+	_, span := telemetryFor(handle).tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	span.End()
+}
+
+func durationMillis(start time.Time) float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
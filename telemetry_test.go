@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"unsafe"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// TestTelemetryFor_DefaultsWhenUnregistered checks that a handle with no
+// registered telemetry falls back to defaultTelemetry, rather than a nil
+// tracer/meter that would panic on first use.
+func TestTelemetryFor_DefaultsWhenUnregistered(t *testing.T) {
+	var fakeHandle int
+	handle := ReliabilityManagerHandle(unsafe.Pointer(&fakeHandle))
+
+	if got := telemetryFor(handle); got != defaultTelemetry {
+		t.Errorf("telemetryFor(unregistered handle) = %p, want defaultTelemetry %p", got, defaultTelemetry)
+	}
+}
+
+// TestRegisterTelemetry_ScopedPerHandle checks that registering telemetry
+// for one handle does not affect what telemetryFor returns for another,
+// and that unregisterTelemetry removes the association again.
+func TestRegisterTelemetry_ScopedPerHandle(t *testing.T) {
+	var fakeA, fakeB int
+	handleA := ReliabilityManagerHandle(unsafe.Pointer(&fakeA))
+	handleB := ReliabilityManagerHandle(unsafe.Pointer(&fakeB))
+
+	custom, err := newHandleTelemetry(defaultTelemetry.tracer, defaultTelemetry.meter)
+	if err != nil {
+		t.Fatalf("newHandleTelemetry failed: %v", err)
+	}
+	registerTelemetry(handleA, custom)
+	defer unregisterTelemetry(handleA)
+
+	if got := telemetryFor(handleA); got != custom {
+		t.Errorf("telemetryFor(handleA) = %p, want custom %p", got, custom)
+	}
+	if got := telemetryFor(handleB); got != defaultTelemetry {
+		t.Errorf("telemetryFor(handleB) = %p, want defaultTelemetry %p (registering A must not affect B)", got, defaultTelemetry)
+	}
+
+	unregisterTelemetry(handleA)
+	if got := telemetryFor(handleA); got != defaultTelemetry {
+		t.Errorf("telemetryFor(handleA) after unregister = %p, want defaultTelemetry %p", got, defaultTelemetry)
+	}
+}
+
+// TestBuildHandleTelemetry_NoOptionsReturnsDefault checks that a manager
+// configured with neither WithOTLPExporter nor WithOTLPMetricReader reuses
+// defaultTelemetry instead of allocating a redundant copy.
+func TestBuildHandleTelemetry_NoOptionsReturnsDefault(t *testing.T) {
+	got, err := buildHandleTelemetry(managerOptions{})
+	if err != nil {
+		t.Fatalf("buildHandleTelemetry failed: %v", err)
+	}
+	if got != defaultTelemetry {
+		t.Errorf("buildHandleTelemetry({}) = %p, want defaultTelemetry %p", got, defaultTelemetry)
+	}
+}
+
+// TestBuildHandleTelemetry_MetricReaderIsHandleScoped checks that configuring
+// an OTLP metric reader for one manager builds its own meter (and thus its
+// own instruments), distinct from defaultTelemetry's, rather than mutating
+// global metrics state.
+func TestBuildHandleTelemetry_MetricReaderIsHandleScoped(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	got, err := buildHandleTelemetry(managerOptions{otlpMetricReader: reader})
+	if err != nil {
+		t.Fatalf("buildHandleTelemetry failed: %v", err)
+	}
+	if got == defaultTelemetry {
+		t.Fatal("buildHandleTelemetry with a metric reader configured returned defaultTelemetry unchanged")
+	}
+	if got.meter == defaultTelemetry.meter {
+		t.Error("handle-scoped meter must not be the same instance as defaultTelemetry's")
+	}
+
+	got.messagesWrappedCounter.Add(context.Background(), 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("reader.Collect failed: %v", err)
+	}
+	if len(rm.ScopeMetrics) == 0 || len(rm.ScopeMetrics[0].Metrics) == 0 {
+		t.Fatal("expected the counter recorded via the handle-scoped meter to reach the configured reader")
+	}
+}
+
+// TestChannelIDFor_ScopedPerHandle checks that the channel ID recorded for
+// one handle (used to attach sds.channel_id to that handle's spans) does not
+// leak to another handle, and that unregisterChannelID clears it again.
+func TestChannelIDFor_ScopedPerHandle(t *testing.T) {
+	var fakeA, fakeB int
+	handleA := ReliabilityManagerHandle(unsafe.Pointer(&fakeA))
+	handleB := ReliabilityManagerHandle(unsafe.Pointer(&fakeB))
+
+	if got := channelIDFor(handleA); got != "" {
+		t.Errorf("channelIDFor(unregistered) = %q, want \"\"", got)
+	}
+
+	registerChannelID(handleA, "channel-a")
+	defer unregisterChannelID(handleA)
+
+	if got := channelIDFor(handleA); got != "channel-a" {
+		t.Errorf("channelIDFor(handleA) = %q, want %q", got, "channel-a")
+	}
+	if got := channelIDFor(handleB); got != "" {
+		t.Errorf("channelIDFor(handleB) = %q, want \"\" (registering A must not affect B)", got)
+	}
+
+	unregisterChannelID(handleA)
+	if got := channelIDFor(handleA); got != "" {
+		t.Errorf("channelIDFor(handleA) after unregister = %q, want \"\"", got)
+	}
+}